@@ -0,0 +1,173 @@
+// Command compress-assets writes precompressed .gz and, if the brotli
+// command is installed, .br variants of the compressible files in the
+// static directories of themes, for the server to send to clients that
+// accept them. The SHA-256 of each file goes into a .sha256 file next to
+// it, so the server can tell whether the variants are still current. It
+// runs at build time, see "go generate ./web".
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// compressible are the extensions of files worth compressing
+var compressible = map[string]bool{
+	".css":         true,
+	".js":          true,
+	".mjs":         true,
+	".json":        true,
+	".map":         true,
+	".webmanifest": true,
+	".html":        true,
+	".txt":         true,
+	".xml":         true,
+	".svg":         true,
+	".ico":         true,
+	".ttf":         true,
+	".otf":         true,
+	".wasm":        true,
+}
+
+// minSize is the size below which compression does not pay off
+const minSize = 256
+
+func main() {
+	clean := flag.Bool("clean", false, "Remove precompressed variants instead of writing them")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-clean] THEMES_DIR...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	brotli, err := exec.LookPath("brotli")
+	if err != nil && !*clean {
+		fmt.Fprintln(os.Stderr, "brotli not found, writing gzip variants only")
+		brotli = ""
+	}
+
+	for _, dir := range flag.Args() {
+		if err := walkStatic(dir, func(path string) error {
+			if *clean {
+				return removeVariants(path)
+			}
+			return compress(path, brotli)
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// walkStatic calls fn for every compressible file below a directory named
+// static in dir
+func walkStatic(dir string, fn func(path string) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		inStatic := false
+		for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+			inStatic = inStatic || part == "static"
+		}
+		if !inStatic || !compressible[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// compress writes path.gz and, with a brotli binary, path.br, along with
+// path.sha256. Variants that would not be smaller than the file are not
+// kept.
+func compress(path, brotli string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() < minSize {
+		return removeVariants(path)
+	}
+
+	if err := writeGzip(path); err != nil {
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if brotli != "" {
+		if out, err := exec.Command(brotli, "--force", "--best", "--output="+path+".br", path).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to compress %s: %w: %s", path, err, out)
+		}
+	}
+
+	for _, variant := range []string{path + ".gz", path + ".br"} {
+		if compressed, err := os.Stat(variant); err == nil && compressed.Size() >= info.Size() {
+			os.Remove(variant)
+		}
+	}
+	if err := writeHash(path); err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	fmt.Println(path)
+	return nil
+}
+
+func writeGzip(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	_, err = zw.Write(data)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeHash records the hash of the file the variants were made from
+func writeHash(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	return os.WriteFile(path+".sha256", []byte(hex.EncodeToString(sum[:])+"\n"), 0644)
+}
+
+// removeVariants removes the precompressed variants of path and their hash
+func removeVariants(path string) error {
+	for _, variant := range []string{path + ".gz", path + ".br", path + ".sha256"} {
+		if err := os.Remove(variant); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
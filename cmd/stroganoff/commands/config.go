@@ -1,10 +1,23 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/internal/logging"
+	"gopkg.in/yaml.v3"
 )
 
 var configCmd = &cobra.Command{
@@ -13,31 +26,321 @@ var configCmd = &cobra.Command{
 	Long:  "Show and manage application configuration",
 }
 
+var configShowOrigin bool
+
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
+	Long: `Show the effective configuration after merging built-in defaults, the
+config file, conf.d fragments, the selected --profile and environment variables. With --origin,
+every value is listed along with the source it came from.`,
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg := config.GetInstance().Get()
+		loader, err := newConfigLoader()
+		if err != nil {
+			return err
+		}
+		defer loader.Stop()
+
+		if err := loadConfig(loader); err != nil {
+			return err
+		}
+
+		if configShowOrigin {
+			return printOrigins(config.GetInstance().Redacted(), config.GetInstance().Origins())
+		}
+
+		data, err := yaml.Marshal(config.GetInstance().Redacted())
+		if err != nil {
+			return fmt.Errorf("failed to render config: %w", err)
+		}
+		fmt.Print(string(data))
+		return nil
+	},
+}
 
-		fmt.Println("Server Configuration:")
-		fmt.Printf("  Host: %s\n", cfg.Server.Host)
-		fmt.Printf("  Port: %d\n", cfg.Server.Port)
-		fmt.Printf("  Theme: %s\n", cfg.Server.Theme)
+var configValidateCmd = &cobra.Command{
+	Use:   "validate <file>",
+	Short: "Validate a configuration file",
+	Long: `Check a configuration file for syntax errors, unknown keys and invalid values.
+The format is taken from --config-format or the file extension. Secret references
+and server.themes_dir are not checked against this machine, so files can be
+validated before they are deployed.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := config.ParseFormat(configFormat)
+		if err != nil {
+			return err
+		}
+		if format == "" {
+			format = config.FormatFor(args[0])
+		}
 
-		fmt.Println("\nAPI Configuration:")
-		fmt.Printf("  Rate Limit: %d requests\n", cfg.API.RateLimit)
-		fmt.Printf("  Rate Limit Window: %d seconds\n", cfg.API.RateLimitWindow)
-		fmt.Printf("  Auth Enabled: %v\n", cfg.API.AuthEnabled)
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		if _, err := config.Parse(data, format); err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+
+		fmt.Printf("%s: configuration is valid\n", args[0])
+		return nil
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration file",
+	Long: `Print a JSON Schema describing every configuration key with its type, default
+value and allowed values. Point editors at it for autocompletion, or use it to
+lint config files in CI. The server validates configurations against the same schema.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render schema: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+var (
+	adminServer string
+	adminToken  string
+)
+
+// configRevision is a config revision as returned by the admin API
+type configRevision struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Hash    string    `json:"hash"`
+	Current bool      `json:"current"`
+}
+
+var configHistoryCmd = &cobra.Command{
+	Use:          "history",
+	Short:        "Show the configuration history of the running server",
+	Long:         "List the configurations the running server has applied, oldest first. The active one is marked with *.",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var resp struct {
+			Revisions []configRevision `json:"revisions"`
+		}
+		if err := adminRequest(http.MethodGet, "/api/admin/config/history", nil, &resp); err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tTIME\tHASH\tSOURCE")
+		for _, rev := range resp.Revisions {
+			marker := " "
+			if rev.Current {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s%d\t%s\t%s\t%s\n", marker, rev.Version, rev.Time.Local().Format(time.RFC3339), shortHash(rev.Hash), rev.Source)
+		}
+		return w.Flush()
+	},
+}
+
+var configRollbackCmd = &cobra.Command{
+	Use:   "rollback <version>",
+	Short: "Roll the running server back to an earlier configuration",
+	Long: `Re-apply a configuration from the running server's history. The rollback stays
+in effect until the config files change again.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
 
-		fmt.Println("\nDatabase Configuration:")
-		fmt.Printf("  Host: %s\n", cfg.Database.Host)
-		fmt.Printf("  Port: %d\n", cfg.Database.Port)
-		fmt.Printf("  Database: %s\n", cfg.Database.Database)
+		var rev configRevision
+		req := map[string]int{"version": version}
+		if err := adminRequest(http.MethodPost, "/api/admin/config/rollback", req, &rev); err != nil {
+			return err
+		}
 
+		fmt.Printf("Rolled back to version %d, now active as version %d (%s)\n", version, rev.Version, shortHash(rev.Hash))
 		return nil
 	},
 }
 
 func init() {
+	configShowCmd.Flags().BoolVar(&configShowOrigin, "origin", false, "Show where each value came from")
+
+	for _, cmd := range []*cobra.Command{configHistoryCmd, configRollbackCmd} {
+		cmd.Flags().StringVar(&adminServer, "server", "", "URL of the running server (default: from server.host, server.port and server.tls_cert)")
+		cmd.Flags().StringVar(&adminToken, "token", "", "API token with the admin scope, if authentication is enabled")
+	}
+
 	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	configCmd.AddCommand(configHistoryCmd)
+	configCmd.AddCommand(configRollbackCmd)
+}
+
+// adminURL returns the base URL of the running server
+func adminURL() (string, error) {
+	if adminServer != "" {
+		return strings.TrimRight(adminServer, "/"), nil
+	}
+
+	loader, err := newConfigLoader()
+	if err != nil {
+		return "", err
+	}
+	defer loader.Stop()
+
+	if err := loadConfig(loader); err != nil {
+		return "", err
+	}
+
+	cfg := config.GetInstance().GetServer()
+	scheme := "http"
+	if cfg.TLSCert != "" {
+		scheme = "https"
+	}
+
+	// A server listening on all interfaces is reached over loopback
+	host := cfg.Host
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::", "[::]":
+		host = "::1"
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(cfg.Port))), nil
+}
+
+// adminRequest calls an admin API endpoint of the running server and
+// decodes the JSON response into out
+func adminRequest(method, path string, body, out interface{}) error {
+	base, err := adminURL()
+	if err != nil {
+		return err
+	}
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, base+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("server returned %s: %s", resp.Status, apiErr.Error)
+		}
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// shortHash abbreviates a content hash for display
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// newConfigLoader creates a loader for the --config file and --conf-dir
+// fragments, and the --config-url endpoint if set. The local file is only
+// read alongside --config-url when --config is given explicitly.
+func newConfigLoader() (*config.Loader, error) {
+	format, err := config.ParseFormat(configFormat)
+	if err != nil {
+		return nil, err
+	}
+	config.GetInstance().SetProfile(profile)
+
+	var sources []config.ConfigSource
+	if configURL == "" || RootCmd.PersistentFlags().Changed("config") {
+		file := config.NewFileSource(configFile)
+		file.SetFormat(format)
+		file.SetConfDir(configDir)
+		sources = append(sources, file)
+	}
+	if configURL != "" {
+		sources = append(sources, config.NewHTTPSource(configURL, configPoll))
+	}
+
+	return config.NewSourceLoader(sources...), nil
+}
+
+// loadConfig performs the initial configuration load. A missing default
+// config file is not an error; defaults, environment and flags still apply.
+func loadConfig(loader *config.Loader) error {
+	if configURL == "" && !RootCmd.PersistentFlags().Changed("config") {
+		if _, err := os.Stat(configFile); errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: config file %s not found, using defaults\n", configFile)
+			return config.GetInstance().LoadLayers()
+		}
+	}
+	return loader.Load()
+}
+
+// setupLogging loads the configuration and logs as configured, for
+// commands other than serve. The returned function closes the log. A
+// configuration that cannot be loaded only costs its logging settings, so
+// that e.g. an upgrade still works when the config is broken.
+func setupLogging() (closeLog func()) {
+	loader, err := newConfigLoader()
+	if err == nil {
+		defer loader.Stop()
+		err = loadConfig(loader)
+	}
+	if err == nil {
+		err = logging.Setup(config.GetInstance().Get().Logging)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; logging with the defaults\n", err)
+		return func() {}
+	}
+	return func() { logging.Close() }
+}
+
+// printOrigins prints every effective config value with its origin
+func printOrigins(cfg *config.Config, origins config.Origins) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tORIGIN")
+
+	for _, path := range origins.Paths() {
+		value, ok := cfg.Lookup(path)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%v\t%s\n", path, value, origins[path])
+	}
+
+	return w.Flush()
 }
@@ -21,6 +21,7 @@ var installCmd = &cobra.Command{
 	Long: `Install the stroganoff application as a system service.
 Supports systemd (Linux), launchd (macOS), and Windows Service.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupLogging()()
 		return performInstall()
 	},
 }
@@ -1,7 +1,10 @@
 package commands
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
+	"github.com/yourusername/stroganoff/internal/config"
 )
 
 var RootCmd = &cobra.Command{
@@ -11,10 +14,27 @@ var RootCmd = &cobra.Command{
 configuration management, web interfaces, API endpoints, and more.`,
 }
 
+var (
+	configFile   string
+	configDir    string
+	configFormat string
+	profile      string
+	configURL    string
+	configPoll   time.Duration
+)
+
 func init() {
+	RootCmd.PersistentFlags().StringVar(&configFile, "config", "config.yaml", "Configuration file path")
+	RootCmd.PersistentFlags().StringVar(&configDir, "conf-dir", "", "Directory of *.yaml, *.json or *.toml fragments merged over the config file (e.g. /etc/stroganoff/conf.d)")
+	RootCmd.PersistentFlags().StringVar(&configURL, "config-url", "", "HTTP(S) endpoint to pull configuration from, merged over the config file")
+	RootCmd.PersistentFlags().DurationVar(&configPoll, "config-poll", config.DefaultPollInterval, "How often to poll --config-url for changes")
+	RootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Config profile to apply (default: $STROGANOFF_PROFILE)")
+	RootCmd.PersistentFlags().StringVar(&configFormat, "config-format", "", "Configuration file format: yaml, json or toml (default: from the file extension)")
+
 	RootCmd.AddCommand(versionCmd)
 	RootCmd.AddCommand(upgradeCmd)
 	RootCmd.AddCommand(installCmd)
 	RootCmd.AddCommand(serveCmd)
 	RootCmd.AddCommand(configCmd)
+	RootCmd.AddCommand(themeCmd)
 }
@@ -1,73 +1,86 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/internal/logging"
 	"github.com/yourusername/stroganoff/internal/monitor"
 	"github.com/yourusername/stroganoff/internal/web"
+	"github.com/yourusername/stroganoff/pkg/version"
 )
 
 var (
-	configFile string
-	webHost    string
-	webPort    int
-	webTheme   string
+	webHost  string
+	webPort  int
+	webTheme string
 )
 
+// serveFlagBindings maps serve flags to the config values they override
+var serveFlagBindings = map[string]string{
+	"host":  "server.host",
+	"port":  "server.port",
+	"theme": "server.theme",
+}
+
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the server",
 	Long:  "Start the stroganoff server with HTTP API and web interface",
+	// Runtime errors such as a port in use are not usage errors
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return startServer()
+		return startServer(cmd)
 	},
 }
 
 func init() {
-	serveCmd.Flags().StringVar(&configFile, "config", "config.yaml", "Configuration file path")
 	serveCmd.Flags().StringVar(&webHost, "host", "localhost", "Server host")
 	serveCmd.Flags().IntVar(&webPort, "port", 8080, "Server port")
 	serveCmd.Flags().StringVar(&webTheme, "theme", "default", "Theme name")
 }
 
-func startServer() error {
-	// Load configuration
-	loader, err := config.NewLoader(configFile)
-	if err != nil && configFile != "config.yaml" {
-		return fmt.Errorf("failed to create config loader: %w", err)
+func startServer(cmd *cobra.Command) error {
+	// Command line flags take precedence over every other config source
+	flags, err := config.NewFlagLayer(cmd.Flags(), serveFlagBindings)
+	if err != nil {
+		return err
 	}
+	config.GetInstance().SetFlags(flags)
 
-	// Try to load config file, but continue if it doesn't exist
-	if err := loader.Load(); err != nil {
-		fmt.Printf("Warning: Could not load config file: %v\n", err)
+	// Load configuration
+	loader, err := newConfigLoader()
+	if err != nil {
+		return err
 	}
+	defer loader.Stop()
 
-	// Start watching for config changes
-	if err := loader.StartWatching(); err != nil {
-		fmt.Printf("Warning: Could not watch config file: %v\n", err)
+	if err := loadConfig(loader); err != nil {
+		return err
 	}
-	defer loader.Stop()
 
-	// Override config from command line flags
 	cfg := config.GetInstance().Get()
-	if webHost != "localhost" {
-		cfg.Server.Host = webHost
-	}
-	if webPort != 8080 {
-		cfg.Server.Port = webPort
-	}
-	if webTheme != "default" {
-		cfg.Server.Theme = webTheme
+
+	// Log as configured from here on, following changes on reload
+	if err := logging.Setup(cfg.Logging); err != nil {
+		return err
 	}
+	defer logging.Close()
+	stopLogging := logging.Watch()
+	defer stopLogging()
+	stopReopen := logging.ReopenOnSignal()
+	defer stopReopen()
 
-	// Update config
-	if err := config.GetInstance().Load(marshalConfig(cfg)); err != nil {
-		return fmt.Errorf("failed to update config: %w", err)
+	// Start watching for config changes
+	if err := loader.StartWatching(); err != nil {
+		slog.Warn("Could not watch config sources, changes require a restart", "error", err)
 	}
 
 	// Initialize monitor
@@ -75,35 +88,46 @@ func startServer() error {
 	defer appMonitor.Stop()
 
 	// Create and start server
-	fmt.Printf("Starting stroganoff server on %s:%d\n", cfg.Server.Host, cfg.Server.Port)
-	fmt.Printf("Theme: %s\n", cfg.Server.Theme)
+	slog.Info("Starting stroganoff server",
+		"addr", fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+		"theme", cfg.Server.Theme,
+		"profile", config.GetInstance().Profile(),
+		"version", version.GetVersion())
 
 	server := web.NewServer()
 
-	// Handle graceful shutdown
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
-	go func() {
-		<-sigCh
-		fmt.Println("\nShutting down server...")
+	// Shut down gracefully on Ctrl-C and on SIGTERM, which systemd and
+	// Docker send
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Run() }()
+
+	select {
+	case err := <-errCh:
 		server.Stop()
-		os.Exit(0)
-	}()
+		return err
+	case <-ctx.Done():
+	}
 
-	return server.Run()
-}
+	// A second signal terminates immediately
+	stop()
+
+	serverCfg := config.GetInstance().GetServer()
+	grace := serverCfg.DrainDelay.Duration() + serverCfg.ShutdownTimeout.Duration()
+	slog.Info("Shutting down server, draining connections", "grace", grace)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Connections did not drain in time and were closed", "error", err)
+	}
+	if err := <-errCh; err != nil {
+		return err
+	}
 
-func marshalConfig(cfg *config.Config) []byte {
-	// Simple YAML marshaling (would be better with proper YAML library)
-	data := fmt.Sprintf(`server:
-  host: %s
-  port: %d
-  theme: %s
-api:
-  rate_limit: %d
-  rate_limit_window: %d
-  auth_enabled: %v
-`, cfg.Server.Host, cfg.Server.Port, cfg.Server.Theme,
-		cfg.API.RateLimit, cfg.API.RateLimitWindow, cfg.API.AuthEnabled)
-	return []byte(data)
+	slog.Info("Server stopped")
+	return nil
 }
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/internal/themes"
+)
+
+var (
+	themesDirFlag string
+	themeForce    bool
+)
+
+var themeCmd = &cobra.Command{
+	Use:   "theme",
+	Short: "Manage web interface themes",
+	Long: `List, install, remove and validate web interface themes. Themes are installed
+into the themes directory, server.themes_dir or --dir, where a running server
+picks them up without a restart.`,
+}
+
+var themeListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List available themes",
+	Long:         "List the built-in themes and those in the themes directory. The configured server.theme is marked with *.",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, active, err := themeSettings()
+		if err != nil {
+			return err
+		}
+
+		list, err := themes.List(dir)
+		if err != nil {
+			return fmt.Errorf("failed to list themes: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tAUTHOR\tPARENT\tSOURCE")
+		for _, info := range list {
+			marker := " "
+			if info.Name == active {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\n", marker, info.Name, info.Version, info.Author, info.Parent, themeSource(info))
+		}
+		return w.Flush()
+	},
+}
+
+var themeInstallCmd = &cobra.Command{
+	Use:   "install <archive>",
+	Short: "Install a theme from a .zip or .tar.gz archive",
+	Long: `Validate the theme in a .zip, .tar.gz or .tgz archive and install it into the
+themes directory. The archive holds the theme's files with its theme.yaml
+manifest at the root or in a single top-level directory. An installed theme of
+the same name is only replaced with --force.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _, err := themeSettings()
+		if err != nil {
+			return err
+		}
+		if dir == "" {
+			return errors.New("no themes directory: set server.themes_dir or pass --dir")
+		}
+
+		m, err := themes.Install(args[0], dir, themeForce)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Installed theme %s %s into %s\n", m.Name, m.Version, dir)
+		return nil
+	},
+}
+
+var themeRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove an installed theme",
+	Long: `Remove a theme from the themes directory. Removing a built-in theme's directory
+removes the files overriding it. The configured server.theme and themes other
+themes inherit from are not removed.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, active, err := themeSettings()
+		if err != nil {
+			return err
+		}
+		if dir == "" {
+			return errors.New("no themes directory: set server.themes_dir or pass --dir")
+		}
+		if args[0] == active && !themes.IsBuiltin(active) {
+			return fmt.Errorf("theme %q is the configured server.theme", active)
+		}
+
+		if err := themes.Remove(args[0], dir); err != nil {
+			return err
+		}
+		fmt.Printf("Removed theme %s from %s\n", args[0], dir)
+		return nil
+	},
+}
+
+var themeValidateCmd = &cobra.Command{
+	Use:   "validate <name|directory|archive>",
+	Short: "Validate a theme",
+	Long: `Check a theme's manifest, parent and required pages. The theme is an available
+theme's name, a directory with a theme.yaml, or a theme archive.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _, err := themeSettings()
+		if err != nil {
+			return err
+		}
+
+		var m *themes.Manifest
+		if info, statErr := os.Stat(args[0]); statErr == nil && info.IsDir() {
+			m, err = themes.Validate(os.DirFS(args[0]), dir)
+		} else if statErr == nil && themes.IsArchive(args[0]) {
+			m, err = themes.ValidateArchive(args[0], dir)
+		} else {
+			m, err = themes.ValidateInstalled(args[0], dir)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+
+		fmt.Printf("%s: theme %s %s is valid\n", args[0], m.Name, m.Version)
+		return nil
+	},
+}
+
+func init() {
+	themeCmd.PersistentFlags().StringVar(&themesDirFlag, "dir", "", "Themes directory (default: server.themes_dir from the configuration)")
+	themeInstallCmd.Flags().BoolVar(&themeForce, "force", false, "Replace an installed theme of the same name")
+
+	themeCmd.AddCommand(themeListCmd)
+	themeCmd.AddCommand(themeInstallCmd)
+	themeCmd.AddCommand(themeRemoveCmd)
+	themeCmd.AddCommand(themeValidateCmd)
+}
+
+// themeSettings returns the themes directory, from --dir or the
+// configuration, and the configured server.theme. With --dir the
+// configuration is not loaded and no theme counts as configured.
+func themeSettings() (dir, active string, err error) {
+	if themesDirFlag != "" {
+		return themesDirFlag, "", nil
+	}
+
+	loader, err := newConfigLoader()
+	if err != nil {
+		return "", "", err
+	}
+	defer loader.Stop()
+
+	if err := loadConfig(loader); err != nil {
+		return "", "", err
+	}
+	cfg := config.GetInstance().GetServer()
+	return cfg.ThemesDir, cfg.Theme, nil
+}
+
+// themeSource describes where a theme comes from
+func themeSource(info themes.Info) string {
+	switch {
+	case info.Builtin && info.External:
+		return "built-in, overridden"
+	case info.Builtin:
+		return "built-in"
+	}
+	return "installed"
+}
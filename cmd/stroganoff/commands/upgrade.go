@@ -26,6 +26,7 @@ var upgradeCmd = &cobra.Command{
 Can upgrade to a specific version or the latest available version.
 Supports both public and private repositories with authentication token.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		defer setupLogging()()
 		return performUpgrade()
 	},
 }
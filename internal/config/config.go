@@ -1,9 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"os"
+	"reflect"
 	"sync"
-
-	"gopkg.in/yaml.v3"
 )
 
 // Config holds the application configuration
@@ -16,46 +17,71 @@ type Config struct {
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	Theme    string `yaml:"theme"`
-	TLSCert  string `yaml:"tls_cert"`
-	TLSKey   string `yaml:"tls_key"`
-	ReadTimeout  int `yaml:"read_timeout"`
-	WriteTimeout int `yaml:"write_timeout"`
+	Host              string         `yaml:"host" desc:"Address to listen on"`
+	Port              int            `yaml:"port" desc:"TCP port to listen on" minimum:"1" maximum:"65535"`
+	Theme             string         `yaml:"theme" desc:"Name of the web interface theme"`
+	ThemesDir         string         `yaml:"themes_dir" desc:"Directory of themes overriding the built-in ones, one subdirectory per theme; its files take precedence over built-in files of the same theme"`
+	TLSCert           string         `yaml:"tls_cert" desc:"Path to the TLS certificate; requires tls_key"`
+	TLSKey            string         `yaml:"tls_key" desc:"Path to the TLS private key; requires tls_cert"`
+	TLSMinVersion     TLSVersionName `yaml:"tls_min_version" desc:"Oldest TLS version accepted" enum:"1.0,1.1,1.2,1.3"`
+	TLSCipherSuites   []string       `yaml:"tls_cipher_suites" desc:"TLS 1.0-1.2 cipher suites to offer, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256; empty uses Go's defaults"`
+	HTTPRedirectPort  int            `yaml:"http_redirect_port" desc:"Port of a plain HTTP listener redirecting to HTTPS; 0 disables it" minimum:"0" maximum:"65535"`
+	ReadTimeout       Duration       `yaml:"read_timeout" desc:"Time allowed to read a whole request including its body, e.g. \"30s\"; bare numbers are seconds" minimum:"0"`
+	ReadHeaderTimeout Duration       `yaml:"read_header_timeout" desc:"Time allowed to read request headers; 0 uses read_timeout" minimum:"0"`
+	WriteTimeout      Duration       `yaml:"write_timeout" desc:"Response write timeout, e.g. \"30s\"; bare numbers are seconds" minimum:"0"`
+	IdleTimeout       Duration       `yaml:"idle_timeout" desc:"How long keep-alive connections may stay idle; 0 uses read_timeout" minimum:"0"`
+	DrainDelay        Duration       `yaml:"drain_delay" desc:"How long /health reports draining before the listener closes on shutdown" minimum:"0"`
+	ShutdownTimeout   Duration       `yaml:"shutdown_timeout" desc:"How long in-flight requests may take to complete on shutdown" minimum:"0"`
+	MaxHeaderBytes    ByteSize       `yaml:"max_header_bytes" desc:"Largest accepted request header block, e.g. \"1MiB\"" minimum:"0"`
+	MaxBodySize       ByteSize       `yaml:"max_body_size" desc:"Largest accepted request body, e.g. \"10MiB\"; 0 means no limit" minimum:"0"`
+	BodyLimits        []BodyLimit    `yaml:"body_limits" desc:"Per-route body size limits as \"PREFIX=SIZE\", e.g. \"/api/upload=100MiB\"; the longest matching prefix replaces max_body_size"`
 }
 
 // APIConfig holds API configuration
 type APIConfig struct {
-	RateLimit        int    `yaml:"rate_limit"`
-	RateLimitWindow  int    `yaml:"rate_limit_window"`
-	AuthEnabled      bool   `yaml:"auth_enabled"`
-	AuthTokenHeader  string `yaml:"auth_token_header"`
-	AllowedOrigins   []string `yaml:"allowed_origins"`
-	CORSEnabled      bool   `yaml:"cors_enabled"`
+	RateLimit       int      `yaml:"rate_limit" desc:"Requests allowed per client and window; 0 disables rate limiting" minimum:"0"`
+	RateLimitWindow Duration `yaml:"rate_limit_window" desc:"Rate limit window, e.g. \"1m\"; bare numbers are seconds"`
+	AuthEnabled     bool     `yaml:"auth_enabled" desc:"Require a bearer token for protected endpoints"`
+	AuthTokenHeader string   `yaml:"auth_token_header" desc:"Header carrying the authentication token"`
+	AdminToken      string   `yaml:"admin_token" desc:"Token with the admin scope that is always accepted, for creating other tokens; at least 32 characters, best given as a secret reference such as ${env:ADMIN_TOKEN}; empty disables it" secret:"true"`
+	AllowedOrigins  []string `yaml:"allowed_origins" desc:"Origins allowed by CORS; \"*\" allows any origin"`
+	CORSEnabled     bool     `yaml:"cors_enabled" desc:"Send CORS headers"`
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	Database string `yaml:"database"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
+	Host     string `yaml:"host" desc:"Database server host"`
+	Port     int    `yaml:"port" desc:"Database server port" minimum:"0" maximum:"65535"`
+	Database string `yaml:"database" desc:"Database name"`
+	User     string `yaml:"user" desc:"Database user"`
+	Password string `yaml:"password" desc:"Database password; may be a secret reference such as ${env:DB_PASS}" secret:"true"`
 }
 
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
-	Level      string `yaml:"level"`
-	Format     string `yaml:"format"`
-	OutputPath string `yaml:"output_path"`
+	Level      string `yaml:"level" desc:"Minimum level of messages to log" enum:"debug,info,warn,error"`
+	Format     string `yaml:"format" desc:"Log line format" enum:"json,text"`
+	OutputPath string `yaml:"output_path" desc:"Log destination: stdout, stderr or a file path"`
+
+	// Rotation and retention apply when logging to a file
+	MaxSize        ByteSize `yaml:"max_size" desc:"Size at which the log file is rotated, e.g. \"100MiB\"; 0 disables size-based rotation" minimum:"0"`
+	RotateInterval Duration `yaml:"rotate_interval" desc:"Rotate the log file at every multiple of this interval since midnight UTC, e.g. \"24h\"; 0 disables time-based rotation" minimum:"0"`
+	Compress       bool     `yaml:"compress" desc:"Gzip rotated log files"`
+	MaxAge         Duration `yaml:"max_age" desc:"Remove rotated log files older than this, e.g. \"720h\"; 0 keeps them regardless of age" minimum:"0"`
+	MaxBackups     int      `yaml:"max_backups" desc:"Number of rotated log files to keep; 0 keeps all" minimum:"0"`
 }
 
 // ConfigManager manages the configuration with singleton pattern
 type ConfigManager struct {
-	config   *Config
-	mu       sync.RWMutex
-	watchers []func(*Config)
+	config      *Config
+	origins     Origins
+	flags       Layer
+	profile     string
+	mu          sync.RWMutex
+	applyMu     sync.Mutex
+	subscribers []*subscription
+	history     []Revision
+	historySize int
 }
 
 var (
@@ -67,36 +93,130 @@ var (
 func GetInstance() *ConfigManager {
 	once.Do(func() {
 		instance = &ConfigManager{
-			config:   &Config{},
-			watchers: make([]func(*Config), 0),
+			config: Defaults(),
 		}
 	})
 	return instance
 }
 
-// Load loads configuration from YAML bytes
-func (cm *ConfigManager) Load(data []byte) error {
+// Parse decodes and validates configuration data on top of the built-in
+// defaults without applying it. Every profile the data declares is checked
+// as well. Secret references are left unresolved and the themes directory
+// is not looked at, so that files can be checked on a machine other than
+// the one they are meant for.
+func Parse(data []byte, format Format) (*Config, error) {
+	layer, err := DecodeLayer("", format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := resolve([]Layer{DefaultsLayer(), layer}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := &ValidationError{}
+	for _, name := range layer.Profiles() {
+		layers, _ := selectProfile([]Layer{layer}, name)
+		_, _, err := resolve(append([]Layer{DefaultsLayer()}, layers...), false)
+		if verr, ok := err.(*ValidationError); ok {
+			for _, fe := range verr.Errors {
+				errs.add(ProfilesKey+"."+name+"."+fe.Path, fe.Value, "%s", fe.Message)
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// SetFlags sets the command line flag layer applied on top of every
+// subsequent load
+func (cm *ConfigManager) SetFlags(flags Layer) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
+	cm.flags = flags
+}
+
+// Load loads configuration from YAML bytes. See LoadLayers for how the
+// data is combined with other sources.
+func (cm *ConfigManager) Load(data []byte) error {
+	layer, err := DecodeLayer("", FormatYAML, data)
+	if err != nil {
+		return err
+	}
+	return cm.LoadLayers(layer)
+}
+
+// LoadLayers resolves the configuration from, in increasing precedence,
+// the built-in defaults, the given file layers each followed by the
+// selected profile (see SetProfile), environment variables (see EnvName)
+// and flags set with SetFlags. The result is validated before it
+// replaces the current configuration; if it is rejected the last good
+// configuration stays active.
+func (cm *ConfigManager) LoadLayers(files ...Layer) error {
+	// Serialise loads so that subscribers see changes in the order they
+	// were resolved
+	cm.applyMu.Lock()
+	defer cm.applyMu.Unlock()
 
-	cfg := &Config{}
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	env, err := NewEnvLayer(os.LookupEnv)
+	if err != nil {
 		return err
 	}
 
-	cm.config = cfg
-	cm.notifyWatchers()
+	cm.mu.RLock()
+	flags := cm.flags
+	cm.mu.RUnlock()
+
+	files, err = selectProfile(files, cm.Profile())
+	if err != nil {
+		return err
+	}
+
+	layers := append([]Layer{DefaultsLayer()}, files...)
+	layers = append(layers, env, flags)
+
+	cfg, origins, err := Resolve(layers...)
+	if err != nil {
+		return err
+	}
+
+	cm.apply(cfg, origins, describeSources(files))
 	return nil
 }
 
+// Origins returns where each value of the current configuration came from
+func (cm *ConfigManager) Origins() Origins {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	origins := make(Origins, len(cm.origins))
+	for path, origin := range cm.origins {
+		origins[path] = origin
+	}
+	return origins
+}
+
 // Get returns a copy of the current configuration
 func (cm *ConfigManager) Get() *Config {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
 	// Return a copy to prevent external modifications
-	configCopy := *cm.config
-	return &configCopy
+	return cm.config.Clone()
+}
+
+// Redacted returns a copy of the current configuration that is safe to
+// display, with secrets replaced by RedactedValue
+func (cm *ConfigManager) Redacted() *Config {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.Redacted(cm.origins)
 }
 
 // GetServer returns the server configuration
@@ -113,15 +233,38 @@ func (cm *ConfigManager) GetAPI() APIConfig {
 	return cm.config.API
 }
 
-// Watch registers a watcher function that gets called on config changes
+// Watch registers a watcher function that gets called with a copy of the
+// new configuration whenever it changes. See Subscribe for details.
 func (cm *ConfigManager) Watch(watcher func(*Config)) {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-	cm.watchers = append(cm.watchers, watcher)
+	cm.Subscribe(func(change Change) {
+		watcher(change.New)
+	})
 }
 
-func (cm *ConfigManager) notifyWatchers() {
-	for _, watcher := range cm.watchers {
-		go watcher(cm.config)
-	}
+// Clone returns a deep copy of c
+func (c *Config) Clone() *Config {
+	clone := *c
+	walkFields(reflect.ValueOf(&clone).Elem(), "", func(path string, field reflect.Value) {
+		if field.Kind() == reflect.Slice && !field.IsNil() {
+			copied := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+			reflect.Copy(copied, field)
+			field.Set(copied)
+		}
+	})
+	return &clone
+}
+
+// Lookup returns the value at the given dotted yaml path, e.g. "server.port"
+func (c *Config) Lookup(path string) (interface{}, bool) {
+	var value interface{}
+	found := false
+
+	walkFields(reflect.ValueOf(c).Elem(), "", func(fieldPath string, field reflect.Value) {
+		if fieldPath == path {
+			value = field.Interface()
+			found = true
+		}
+	})
+
+	return value, found
 }
@@ -0,0 +1,772 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"server.port", "STROGANOFF_SERVER_PORT"},
+		{"api.allowed_origins", "STROGANOFF_API_ALLOWED_ORIGINS"},
+		{"logging.output_path", "STROGANOFF_LOGGING_OUTPUT_PATH"},
+	}
+
+	for _, test := range tests {
+		if got := EnvName(test.path); got != test.expected {
+			t.Fatalf("EnvName(%q) = %q, want %q", test.path, got, test.expected)
+		}
+	}
+}
+
+func TestLoadAppliesEnv(t *testing.T) {
+	t.Setenv("STROGANOFF_SERVER_PORT", "9090")
+	t.Setenv("STROGANOFF_SERVER_THEME", "dark")
+	t.Setenv("STROGANOFF_API_AUTH_ENABLED", "true")
+	t.Setenv("STROGANOFF_API_ALLOWED_ORIGINS", "https://a.example, https://b.example")
+	t.Setenv("STROGANOFF_DATABASE_PASSWORD", "secret")
+	t.Setenv("STROGANOFF_LOGGING_LEVEL", "debug")
+
+	cm := &ConfigManager{config: &Config{}}
+	err := cm.Load([]byte("server:\n  host: example.com\n  port: 8080\n  theme: default\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	cfg := cm.Get()
+	if cfg.Server.Host != "example.com" {
+		t.Fatalf("Server.Host = %q, want value from file", cfg.Server.Host)
+	}
+	if cfg.Server.Port != 9090 {
+		t.Fatalf("Server.Port = %d, want 9090", cfg.Server.Port)
+	}
+	if cfg.Server.Theme != "dark" {
+		t.Fatalf("Server.Theme = %q, want dark", cfg.Server.Theme)
+	}
+	if !cfg.API.AuthEnabled {
+		t.Fatal("API.AuthEnabled should be true")
+	}
+	if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(cfg.API.AllowedOrigins, want) {
+		t.Fatalf("API.AllowedOrigins = %v, want %v", cfg.API.AllowedOrigins, want)
+	}
+	if cfg.Database.Password != "secret" {
+		t.Fatalf("Database.Password = %q, want secret", cfg.Database.Password)
+	}
+	if cfg.Logging.Level != "debug" {
+		t.Fatalf("Logging.Level = %q, want debug", cfg.Logging.Level)
+	}
+}
+
+func TestLoadRejectsInvalidEnv(t *testing.T) {
+	t.Setenv("STROGANOFF_SERVER_PORT", "not-a-number")
+
+	cm := &ConfigManager{config: &Config{}}
+	if err := cm.Load([]byte("server:\n  port: 8080\n")); err == nil {
+		t.Fatal("Load should fail for an invalid environment override")
+	}
+}
+
+func TestParseReportsUnknownKeys(t *testing.T) {
+	_, err := Parse([]byte("server:\n  prot: 8080\nmetrics: true\n"), FormatYAML)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Parse error = %v, want *ValidationError", err)
+	}
+
+	if len(verr.Errors) != 2 || verr.Errors[0].Path != "metrics" || verr.Errors[1].Path != "server.prot" {
+		t.Fatalf("unexpected errors: %v", verr)
+	}
+}
+
+func TestParseReportsInvalidValues(t *testing.T) {
+	data := []byte(`server:
+  port: 0
+  theme: purple
+api:
+  rate_limit: -5
+  admin_token: secret
+logging:
+  level: verbose
+`)
+
+	_, err := Parse(data, FormatYAML)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Parse error = %v, want *ValidationError", err)
+	}
+
+	got := make(map[string]bool)
+	for _, fe := range verr.Errors {
+		got[fe.Path] = true
+	}
+	for _, path := range []string{"server.port", "server.theme", "api.rate_limit", "api.admin_token", "logging.level"} {
+		if !got[path] {
+			t.Fatalf("expected error for %s, got %v", path, verr)
+		}
+	}
+}
+
+func TestParseSkipsHostChecks(t *testing.T) {
+	data := []byte("server:\n  theme: custom\n  themes_dir: " + filepath.Join(t.TempDir(), "missing") + "\n")
+
+	// The themes directory may exist where the file is deployed
+	if _, err := Parse(data, FormatYAML); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	cm := &ConfigManager{config: Defaults()}
+	verr, ok := cm.Load(data).(*ValidationError)
+	if !ok || len(verr.Errors) != 2 || verr.Errors[0].Path != "server.themes_dir" || verr.Errors[1].Path != "server.theme" {
+		t.Fatalf("Load error = %v, want themes_dir and theme errors", verr)
+	}
+}
+
+func TestParseReportsTypeErrors(t *testing.T) {
+	_, err := Parse([]byte("server:\n  port: eighty\napi:\n  auth_enabled: yes please\n"), FormatYAML)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Parse error = %v, want *ValidationError", err)
+	}
+
+	if len(verr.Errors) != 2 || verr.Errors[0].Path != "api.auth_enabled" || verr.Errors[1].Path != "server.port" {
+		t.Fatalf("unexpected errors: %v", verr)
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	inputs := map[Format]string{
+		FormatYAML: "server:\n  port: 9000\n  theme: dark\napi:\n  allowed_origins: [https://a.example]\n  rate_limit: 10\n",
+		FormatJSON: `{"server": {"port": 9000, "theme": "dark"}, "api": {"allowed_origins": ["https://a.example"], "rate_limit": 10}}`,
+		FormatTOML: "[server]\nport = 9000\ntheme = \"dark\"\n\n[api]\nallowed_origins = [\"https://a.example\"]\nrate_limit = 10\n",
+	}
+
+	for format, data := range inputs {
+		cfg, err := Parse([]byte(data), format)
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", format, err)
+		}
+		if cfg.Server.Port != 9000 || cfg.Server.Theme != "dark" || cfg.API.RateLimit != 10 {
+			t.Fatalf("%s: unexpected config %+v", format, cfg)
+		}
+		if !reflect.DeepEqual(cfg.API.AllowedOrigins, []string{"https://a.example"}) {
+			t.Fatalf("%s: API.AllowedOrigins = %v", format, cfg.API.AllowedOrigins)
+		}
+		if cfg.Server.Host != "localhost" {
+			t.Fatalf("%s: Server.Host = %q, want default", format, cfg.Server.Host)
+		}
+	}
+}
+
+func TestParseFormatsReportErrors(t *testing.T) {
+	inputs := map[Format]string{
+		FormatJSON: `{"server": {"prot": 8080, "port": "eighty"}}`,
+		FormatTOML: "[server]\nprot = 8080\nport = \"eighty\"\n",
+	}
+
+	for format, data := range inputs {
+		_, err := Parse([]byte(data), format)
+		verr, ok := err.(*ValidationError)
+		if !ok {
+			t.Fatalf("%s: Parse error = %v, want *ValidationError", format, err)
+		}
+		if len(verr.Errors) != 2 || verr.Errors[0].Path != "server.port" || verr.Errors[1].Path != "server.prot" {
+			t.Fatalf("%s: unexpected errors: %v", format, verr)
+		}
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := map[string]Format{
+		"config.yaml":   FormatYAML,
+		"config.yml":    FormatYAML,
+		"config.json":   FormatJSON,
+		"/etc/app.TOML": FormatTOML,
+		"config":        FormatYAML,
+	}
+
+	for path, want := range tests {
+		if got := FormatFor(path); got != want {
+			t.Fatalf("FormatFor(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	if _, err := ParseFormat("ini"); err == nil {
+		t.Fatal("ParseFormat should reject unsupported formats")
+	}
+}
+
+func TestLoadKeepsLastGoodConfig(t *testing.T) {
+	cm := &ConfigManager{config: &Config{}}
+
+	if err := cm.Load([]byte("server:\n  port: 8080\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if err := cm.Load([]byte("server:\n  port: 0\n")); err == nil {
+		t.Fatal("Load should reject port 0")
+	}
+
+	if port := cm.GetServer().Port; port != 8080 {
+		t.Fatalf("Server.Port = %d, want last good value 8080", port)
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	file, err := NewFileLayer("config.yaml", []byte("server:\n  host: file.example\n  port: 7000\ndatabase:\n  password: hunter2\n"))
+	if err != nil {
+		t.Fatalf("NewFileLayer failed: %v", err)
+	}
+
+	env, err := NewEnvLayer(func(name string) (string, bool) {
+		if name == "STROGANOFF_SERVER_PORT" {
+			return "7100", true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatalf("NewEnvLayer failed: %v", err)
+	}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("port", 8080, "")
+	flags.String("theme", "default", "")
+	if err := flags.Parse([]string{"--port", "7200"}); err != nil {
+		t.Fatalf("flag parse failed: %v", err)
+	}
+	flagLayer, err := NewFlagLayer(flags, map[string]string{"port": "server.port", "theme": "server.theme"})
+	if err != nil {
+		t.Fatalf("NewFlagLayer failed: %v", err)
+	}
+
+	cfg, origins, err := Resolve(DefaultsLayer(), file, env, flagLayer)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if cfg.Server.Port != 7200 {
+		t.Fatalf("Server.Port = %d, want flag value 7200", cfg.Server.Port)
+	}
+	if cfg.Server.Host != "file.example" {
+		t.Fatalf("Server.Host = %q, want file value", cfg.Server.Host)
+	}
+	if cfg.Database.Password != "hunter2" {
+		t.Fatalf("Database.Password = %q, want file value", cfg.Database.Password)
+	}
+	if cfg.Logging.Level != "info" {
+		t.Fatalf("Logging.Level = %q, want default info", cfg.Logging.Level)
+	}
+
+	expected := map[string]string{
+		"server.port":       "flag --port",
+		"server.host":       "file config.yaml",
+		"server.theme":      "default",
+		"database.password": "file config.yaml",
+	}
+	for path, want := range expected {
+		if got := origins[path].String(); got != want {
+			t.Fatalf("origin of %s = %q, want %q", path, got, want)
+		}
+	}
+
+	env, _ = NewEnvLayer(func(name string) (string, bool) {
+		return "7100", name == "STROGANOFF_SERVER_PORT"
+	})
+	_, origins, err = Resolve(DefaultsLayer(), file, env)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got := origins["server.port"].String(); got != "env STROGANOFF_SERVER_PORT" {
+		t.Fatalf("origin of server.port = %q, want env STROGANOFF_SERVER_PORT", got)
+	}
+}
+
+func TestResolveKeepsUnsetFields(t *testing.T) {
+	file, err := NewFileLayer("config.yaml", []byte("server:\n  port: 9000\n"))
+	if err != nil {
+		t.Fatalf("NewFileLayer failed: %v", err)
+	}
+
+	cfg, _, err := Resolve(DefaultsLayer(), file)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	defaults := Defaults()
+	defaults.Server.Port = 9000
+	if !reflect.DeepEqual(cfg, defaults) {
+		t.Fatalf("Resolve = %+v, want defaults with port 9000", cfg)
+	}
+}
+
+func TestResolveSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "db")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_DB_USER", "alice")
+
+	file, err := NewFileLayer("config.yaml", []byte(`database:
+  user: "${env:TEST_DB_USER}"
+  password: "file:`+secretFile+`"
+  host: "${cmd:echo db.internal}"
+`))
+	if err != nil {
+		t.Fatalf("NewFileLayer failed: %v", err)
+	}
+
+	cfg, origins, err := Resolve(DefaultsLayer(), file)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if cfg.Database.User != "alice" {
+		t.Fatalf("Database.User = %q, want alice", cfg.Database.User)
+	}
+	if cfg.Database.Password != "from-file" {
+		t.Fatalf("Database.Password = %q, want from-file", cfg.Database.Password)
+	}
+	if cfg.Database.Host != "db.internal" {
+		t.Fatalf("Database.Host = %q, want db.internal", cfg.Database.Host)
+	}
+
+	redacted := cfg.Redacted(origins)
+	for name, value := range map[string]string{
+		"user":     redacted.Database.User,
+		"password": redacted.Database.Password,
+		"host":     redacted.Database.Host,
+	} {
+		if value != RedactedValue {
+			t.Fatalf("redacted database.%s = %q, want %q", name, value, RedactedValue)
+		}
+	}
+	if redacted.Database.Database != "stroganoff" {
+		t.Fatalf("non-secret value was redacted: %q", redacted.Database.Database)
+	}
+	if cfg.Database.Password != "from-file" {
+		t.Fatal("Redacted modified the original config")
+	}
+}
+
+func TestResolveReportsMissingSecret(t *testing.T) {
+	file, err := NewFileLayer("config.yaml", []byte("database:\n  password: ${env:TEST_MISSING_SECRET}\n"))
+	if err != nil {
+		t.Fatalf("NewFileLayer failed: %v", err)
+	}
+
+	_, _, err = Resolve(DefaultsLayer(), file)
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 1 || verr.Errors[0].Path != "database.password" {
+		t.Fatalf("Resolve error = %v, want error for database.password", err)
+	}
+}
+
+func TestRedactedTaggedSecret(t *testing.T) {
+	cfg := Defaults()
+	cfg.Database.Password = "plaintext"
+
+	if got := cfg.Redacted(nil).Database.Password; got != RedactedValue {
+		t.Fatalf("redacted password = %q, want %q", got, RedactedValue)
+	}
+}
+
+func TestSubscribeDeliversOrderedDiff(t *testing.T) {
+	cm := &ConfigManager{config: Defaults()}
+
+	var calls []string
+	var apiChange Change
+	cm.Subscribe(func(c Change) { calls = append(calls, "all") })
+	unsubscribe := cm.Subscribe(func(c Change) {
+		calls = append(calls, "api")
+		apiChange = c
+	}, "api")
+	cm.Subscribe(func(c Change) { calls = append(calls, "logging") }, "logging")
+
+	if err := cm.Load([]byte("api:\n  rate_limit: 5\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if want := []string{"all", "api"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	if len(apiChange.Diff) != 1 {
+		t.Fatalf("Diff = %+v, want a single change", apiChange.Diff)
+	}
+	fc := apiChange.Diff[0]
+	if fc.Path != "api.rate_limit" || fc.Section != "api" || fc.Old != 100 || fc.New != 5 {
+		t.Fatalf("unexpected change %+v", fc)
+	}
+	if apiChange.Old.API.RateLimit != 100 || apiChange.New.API.RateLimit != 5 {
+		t.Fatalf("snapshots = %d -> %d, want 100 -> 5", apiChange.Old.API.RateLimit, apiChange.New.API.RateLimit)
+	}
+
+	// Snapshots belong to the subscriber
+	apiChange.New.API.RateLimit = 999
+	if cm.GetAPI().RateLimit != 5 {
+		t.Fatal("modifying a snapshot changed the live configuration")
+	}
+
+	// Reloading identical content notifies nobody
+	calls = nil
+	if err := cm.Load([]byte("api:\n  rate_limit: 5\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("calls = %v, want none for an unchanged config", calls)
+	}
+
+	unsubscribe()
+	if err := cm.Load([]byte("api:\n  rate_limit: 6\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if want := []string{"all"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v after unsubscribe", calls, want)
+	}
+}
+
+func TestHistoryRecordsAndRollsBack(t *testing.T) {
+	cm := &ConfigManager{config: Defaults()}
+	cm.SetHistorySize(3)
+
+	for _, limit := range []string{"1", "2", "2", "3", "4"} {
+		if err := cm.Load([]byte("api:\n  rate_limit: " + limit + "\n")); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+	}
+
+	// Identical loads are not recorded and only the newest three are kept
+	history := cm.History()
+	var versions []int
+	for _, rev := range history {
+		versions = append(versions, rev.Version)
+	}
+	if want := []int{2, 3, 4}; !reflect.DeepEqual(versions, want) {
+		t.Fatalf("versions = %v, want %v", versions, want)
+	}
+	if history[0].Config.API.RateLimit != 2 || history[0].Source != "inline" || len(history[0].Hash) != 64 {
+		t.Fatalf("unexpected revision %+v", history[0])
+	}
+
+	// The hash covers resolved secrets, so it must not be a plain digest
+	// that could be checked against guesses
+	data, _ := yaml.Marshal(history[0].Config)
+	if sum := sha256.Sum256(data); history[0].Hash == hex.EncodeToString(sum[:]) {
+		t.Error("revision hash is an unkeyed sha256 of the configuration")
+	}
+
+	var notified *Config
+	cm.Subscribe(func(c Change) { notified = c.New })
+
+	rev, err := cm.Rollback(2)
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if rev.Version != 5 || rev.Source != "rollback to version 2" || rev.Hash != history[0].Hash {
+		t.Fatalf("unexpected rollback revision %+v", rev)
+	}
+	if cm.GetAPI().RateLimit != 2 || notified == nil || notified.API.RateLimit != 2 {
+		t.Fatal("rollback did not apply and notify the old configuration")
+	}
+
+	if _, err := cm.Rollback(1); err == nil {
+		t.Fatal("Rollback should fail for a version that is no longer retained")
+	}
+}
+
+func TestSchemaDescribesConfig(t *testing.T) {
+	schema := Schema()
+	if schema["$schema"] != SchemaDialect || schema["additionalProperties"] != false {
+		t.Fatalf("unexpected schema header: %v", schema)
+	}
+
+	property := func(path string) map[string]interface{} {
+		node := schema
+		for _, key := range strings.Split(path, ".") {
+			node = node["properties"].(map[string]interface{})[key].(map[string]interface{})
+		}
+		return node
+	}
+
+	port := property("server.port")
+	if port["type"] != "integer" || port["default"] != 8080 || port["minimum"] != 1.0 || port["maximum"] != 65535.0 {
+		t.Fatalf("server.port schema = %v", port)
+	}
+	if port["description"] == nil {
+		t.Fatal("server.port has no description")
+	}
+
+	level := property("logging.level")
+	if !reflect.DeepEqual(level["enum"], []string{"debug", "info", "warn", "error"}) || level["default"] != "info" {
+		t.Fatalf("logging.level schema = %v", level)
+	}
+
+	origins := property("api.allowed_origins")
+	if origins["type"] != "array" || !reflect.DeepEqual(origins["items"], map[string]interface{}{"type": "string"}) {
+		t.Fatalf("api.allowed_origins schema = %v", origins)
+	}
+
+	// The schema must be serialisable for the config schema command
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+}
+
+func TestValidateUsesSchema(t *testing.T) {
+	cfg := Defaults()
+	cfg.Logging.Format = "xml"
+	cfg.Database.Port = 70000
+	cfg.Server.ReadTimeout = Duration(-time.Second)
+
+	verr, ok := cfg.Validate().(*ValidationError)
+	if !ok {
+		t.Fatal("Validate should reject values outside the schema")
+	}
+
+	var got []string
+	for _, fe := range verr.Errors {
+		got = append(got, fe.Error())
+	}
+	want := []string{
+		"database.port: must be between 0 and 65535 (got 70000)",
+		"logging.format: must be one of json, text (got xml)",
+		"server.read_timeout: must not be negative (got -1s)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("errors = %q, want %q", got, want)
+	}
+}
+
+const profileConfig = `server:
+  host: 0.0.0.0
+  port: 8080
+logging:
+  level: info
+profiles:
+  dev:
+    logging:
+      level: debug
+  prod:
+    server:
+      port: 443
+    api:
+      auth_enabled: true
+`
+
+func TestLoadAppliesProfile(t *testing.T) {
+	cm := &ConfigManager{config: Defaults()}
+	cm.SetProfile("prod")
+	if err := cm.Load([]byte(profileConfig)); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	cfg := cm.Get()
+	if cfg.Server.Host != "0.0.0.0" || cfg.Server.Port != 443 || !cfg.API.AuthEnabled || cfg.Logging.Level != "info" {
+		t.Fatalf("unexpected prod config %+v", cfg)
+	}
+	if origin := cm.Origins()["server.port"].String(); origin != "file profile prod" {
+		t.Fatalf("server.port origin = %q", origin)
+	}
+
+	// The environment selects a profile when none is set explicitly
+	t.Setenv(ProfileEnv, "dev")
+	cm.SetProfile("")
+	if err := cm.Load([]byte(profileConfig)); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg := cm.Get(); cfg.Server.Port != 8080 || cfg.Logging.Level != "debug" {
+		t.Fatalf("unexpected dev config %+v", cfg)
+	}
+
+	cm.SetProfile("staging")
+	if err := cm.Load([]byte(profileConfig)); err == nil {
+		t.Fatal("Load should fail for an undefined profile")
+	}
+}
+
+func TestParseValidatesProfiles(t *testing.T) {
+	data := []byte(profileConfig + "  broken:\n    server:\n      port: 0\n      prot: 1\n")
+
+	_, err := Parse(data, FormatYAML)
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 1 || verr.Errors[0].Path != "profiles.broken.server.prot" {
+		t.Fatalf("Parse error = %v, want unknown field in profile", err)
+	}
+
+	data = []byte(profileConfig + "  broken:\n    server:\n      port: 0\n")
+	_, err = Parse(data, FormatYAML)
+	verr, ok = err.(*ValidationError)
+	if !ok || len(verr.Errors) != 1 || verr.Errors[0].Path != "profiles.broken.server.port" {
+		t.Fatalf("Parse error = %v, want invalid port in profile", err)
+	}
+}
+
+func TestParseDurationsAndSizes(t *testing.T) {
+	cfg, err := Parse([]byte("server:\n  read_timeout: 45\n  write_timeout: 1m30s\n  max_body_size: 512KiB\napi:\n  rate_limit_window: 250ms\n"), FormatYAML)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if got := cfg.Server.ReadTimeout.Duration(); got != 45*time.Second {
+		t.Fatalf("ReadTimeout = %v, want bare integers read as seconds", got)
+	}
+	if got := cfg.Server.WriteTimeout.Duration(); got != 90*time.Second {
+		t.Fatalf("WriteTimeout = %v, want 1m30s", got)
+	}
+	if got := cfg.API.RateLimitWindow.Duration(); got != 250*time.Millisecond {
+		t.Fatalf("RateLimitWindow = %v, want 250ms", got)
+	}
+	if cfg.Server.MaxBodySize != 512*KiB {
+		t.Fatalf("MaxBodySize = %v, want 512KiB", cfg.Server.MaxBodySize)
+	}
+
+	// JSON numbers are floats, including large ones
+	cfg, err = Parse([]byte(`{"server": {"read_timeout": 1.5, "max_body_size": 1048576}, "api": {"rate_limit_window": 3600}}`), FormatJSON)
+	if err != nil {
+		t.Fatalf("Parse JSON failed: %v", err)
+	}
+	if cfg.Server.MaxBodySize != MiB {
+		t.Fatalf("MaxBodySize = %v, want 1MiB", cfg.Server.MaxBodySize)
+	}
+	if got := cfg.Server.ReadTimeout.Duration(); got != 1500*time.Millisecond {
+		t.Fatalf("ReadTimeout = %v, want 1.5s", got)
+	}
+	if got := cfg.API.RateLimitWindow.Duration(); got != time.Hour {
+		t.Fatalf("RateLimitWindow = %v, want 1h", got)
+	}
+
+	_, err = Parse([]byte("server:\n  read_timeout: soon\n  max_body_size: 10XB\n"), FormatYAML)
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 2 {
+		t.Fatalf("Parse error = %v, want errors for both fields", err)
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := map[string]ByteSize{
+		"1024":    1024,
+		"10MiB":   10 * MiB,
+		"10 mib":  10 * MiB,
+		"1.5KiB":  1536,
+		"1MB":     1000000,
+		"2G":      2 * GiB,
+		"0":       0,
+		"100B":    100,
+		" 3 TiB ": 3 * TiB,
+	}
+
+	for input, want := range tests {
+		var got ByteSize
+		if err := got.UnmarshalText([]byte(input)); err != nil {
+			t.Fatalf("UnmarshalText(%q) failed: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("UnmarshalText(%q) = %d, want %d", input, got, want)
+		}
+	}
+
+	if s := (10 * MiB).String(); s != "10MiB" {
+		t.Fatalf("String() = %q, want 10MiB", s)
+	}
+	if s := ByteSize(1500).String(); s != "1500B" {
+		t.Fatalf("String() = %q, want 1500B", s)
+	}
+}
+
+func TestValidateTLSSettings(t *testing.T) {
+	cfg := Defaults()
+	cfg.Server.TLSMinVersion = "1.4"
+	cfg.Server.TLSCipherSuites = []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA"}
+	cfg.Server.HTTPRedirectPort = 8081
+
+	verr, ok := cfg.Validate().(*ValidationError)
+	if !ok {
+		t.Fatal("Validate should reject the TLS settings")
+	}
+
+	got := make(map[string]bool)
+	for _, fe := range verr.Errors {
+		got[fe.Path] = true
+	}
+	for _, path := range []string{"server.tls_min_version", "server.tls_cipher_suites[1]", "server.http_redirect_port"} {
+		if !got[path] {
+			t.Fatalf("expected error for %s, got %v", path, verr)
+		}
+	}
+	if got["server.tls_cipher_suites[0]"] {
+		t.Fatalf("secure cipher suite rejected: %v", verr)
+	}
+}
+
+func TestTLSMinVersionAcceptsNumbers(t *testing.T) {
+	for input, want := range map[string]TLSVersionName{
+		`"1.1"`: "1.1",
+		"1.0":   "1.0",
+		"1":     "1.0",
+		"1.2":   "1.2",
+		"1.30":  "1.3",
+	} {
+		cfg, err := Parse([]byte("server:\n  tls_min_version: "+input+"\n"), FormatYAML)
+		if err != nil {
+			t.Errorf("Parse(tls_min_version: %s) failed: %v", input, err)
+			continue
+		}
+		if cfg.Server.TLSMinVersion != want {
+			t.Errorf("tls_min_version: %s = %q, want %q", input, cfg.Server.TLSMinVersion, want)
+		}
+	}
+
+	if _, err := Parse([]byte("server:\n  tls_min_version: 1.4\n"), FormatYAML); err == nil {
+		t.Error("Parse should reject tls_min_version 1.4")
+	}
+
+	property := Schema()["properties"].(map[string]interface{})["server"].(map[string]interface{})["properties"].(map[string]interface{})["tls_min_version"].(map[string]interface{})
+	if !reflect.DeepEqual(property["type"], []string{"string", "number"}) || !reflect.DeepEqual(property["enum"], []interface{}{"1.0", "1.1", "1.2", "1.3", 1.0, 1.1, 1.2, 1.3}) {
+		t.Errorf("tls_min_version schema = %v, want strings and numbers", property)
+	}
+}
+
+func TestBodyLimits(t *testing.T) {
+	cfg, err := Parse([]byte(`
+server:
+  max_body_size: 1MiB
+  body_limits:
+    - /api=64KiB
+    - /api/upload=100MiB
+    - /api/none=0
+`), FormatYAML)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := map[string]ByteSize{
+		"/":              MiB,
+		"/api/heartbeat": 64 * KiB,
+		"/api/upload/x":  100 * MiB,
+		"/api/none":      0,
+	}
+	for path, want := range tests {
+		if got := cfg.Server.BodyLimitFor(path); got != want {
+			t.Fatalf("BodyLimitFor(%q) = %s, want %s", path, got, want)
+		}
+	}
+
+	for _, invalid := range []string{"api=1KiB", "/api", "/api=lots", "/api=-1"} {
+		var l BodyLimit
+		if err := l.UnmarshalText([]byte(invalid)); err == nil {
+			t.Fatalf("UnmarshalText(%q) should fail", invalid)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// decodeTree decodes a configuration tree into cfg. Unlike yaml.Unmarshal
+// it rejects keys that do not map to a Config field and reports type
+// mismatches with the dotted path of the offending field.
+func decodeTree(tree map[string]interface{}, cfg *Config) error {
+	errs := &ValidationError{}
+	decodeValue(reflect.ValueOf(cfg).Elem(), tree, "", errs)
+	return errs.err()
+}
+
+// decodeValue stores raw, a value produced by the YAML decoder or taken
+// from a typed layer, into v
+func decodeValue(v reflect.Value, raw interface{}, path string, errs *ValidationError) {
+	if raw == nil {
+		return
+	}
+
+	if rv := reflect.ValueOf(raw); rv.Type().AssignableTo(v.Type()) && v.Kind() != reflect.Struct {
+		v.Set(rv)
+		return
+	}
+
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := u.UnmarshalText([]byte(scalarText(raw))); err != nil {
+				errs.add(path, raw, "%v", err)
+			}
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			errs.add(path, raw, "expected a mapping")
+			return
+		}
+		decodeStruct(v, m, path, errs)
+
+	case reflect.String:
+		switch s := raw.(type) {
+		case string:
+			v.SetString(s)
+		case bool, int, int64, uint64, float64:
+			v.SetString(fmt.Sprint(s))
+		default:
+			errs.add(path, raw, "expected a string")
+		}
+
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			errs.add(path, raw, "expected a boolean")
+			return
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt64(raw)
+		if !ok || v.OverflowInt(n) {
+			errs.add(path, raw, "expected an integer")
+			return
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := toInt64(raw)
+		if !ok || n < 0 || v.OverflowUint(uint64(n)) {
+			errs.add(path, raw, "expected an unsigned integer")
+			return
+		}
+		v.SetUint(uint64(n))
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat64(raw)
+		if !ok {
+			errs.add(path, raw, "expected a number")
+			return
+		}
+		v.SetFloat(f)
+
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			errs.add(path, raw, "expected a list")
+			return
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			decodeValue(slice.Index(i), item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+		v.Set(slice)
+
+	default:
+		errs.add(path, nil, "unsupported field type %s", v.Type())
+	}
+}
+
+// decodeStruct decodes a YAML mapping into the struct v, reporting keys
+// that have no matching field
+func decodeStruct(v reflect.Value, m map[string]interface{}, path string, errs *ValidationError) {
+	fields := make(map[string]int)
+	for i := 0; i < v.NumField(); i++ {
+		if name := yamlName(v.Type().Field(i)); name != "" {
+			fields[name] = i
+		}
+	}
+
+	for _, key := range sortedKeys(m) {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+
+		i, ok := fields[key]
+		if !ok {
+			errs.add(fieldPath, nil, "unknown field")
+			continue
+		}
+		decodeValue(v.Field(i), m[key], fieldPath, errs)
+	}
+}
+
+func toInt64(raw interface{}) (int64, bool) {
+	switch n := raw.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case float64:
+		if n != math.Trunc(n) || n > math.MaxInt64 || n < math.MinInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func toFloat64(raw interface{}) (float64, bool) {
+	switch n := raw.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// scalarText returns the text form of a scalar for TextUnmarshalers. Floats,
+// as JSON numbers are decoded, are written without an exponent so that
+// 1048576 does not reach a unit parser as "1.048576e+06".
+func scalarText(raw interface{}) string {
+	if f, ok := raw.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(raw)
+}
+
+// sortedKeys returns the keys of m in lexical order so that errors are
+// reported deterministically
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
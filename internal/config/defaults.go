@@ -0,0 +1,48 @@
+package config
+
+import "time"
+
+// Defaults returns the built-in configuration. It is the lowest precedence
+// layer: any value set by a config file, environment variable or flag
+// replaces the corresponding default.
+func Defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host:              "localhost",
+			Port:              8080,
+			Theme:             "default",
+			TLSMinVersion:     "1.2",
+			ReadTimeout:       Duration(30 * time.Second),
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownTimeout:   Duration(30 * time.Second),
+			MaxHeaderBytes:    MiB,
+			MaxBodySize:       10 * MiB,
+			BodyLimits:        []BodyLimit{{Prefix: "/api/auth/token", Size: 64 * KiB}},
+		},
+		API: APIConfig{
+			RateLimit:       100,
+			RateLimitWindow: Duration(time.Minute),
+			AuthEnabled:     false,
+			AuthTokenHeader: "Authorization",
+			AllowedOrigins:  []string{},
+			CORSEnabled:     false,
+		},
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5432,
+			Database: "stroganoff",
+			User:     "postgres",
+		},
+		Logging: LoggingConfig{
+			Level:      "info",
+			Format:     "json",
+			OutputPath: "stdout",
+			MaxSize:    100 * MiB,
+			Compress:   true,
+			MaxAge:     Duration(30 * 24 * time.Hour),
+			MaxBackups: 10,
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a configuration file format
+type Format string
+
+// Supported configuration file formats
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// ParseFormat returns the format with the given name. The empty string
+// means the format is chosen from the file extension.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return "", nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "json":
+		return FormatJSON, nil
+	case "toml":
+		return FormatTOML, nil
+	}
+	return "", fmt.Errorf("unsupported config format %q (want yaml, json or toml)", name)
+}
+
+// FormatFor returns the format of a config file based on its extension.
+// Files without a recognised extension are treated as YAML.
+func FormatFor(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatYAML
+}
+
+// isConfigExt reports whether ext is the extension of a supported format
+func isConfigExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml", ".json", ".toml":
+		return true
+	}
+	return false
+}
+
+// decodeFormat decodes data into a configuration tree
+func decodeFormat(format Format, data []byte) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+
+	switch format {
+	case FormatYAML, "":
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		if len(bytes.TrimSpace(data)) == 0 {
+			break
+		}
+		if err := json.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", format)
+	}
+
+	if tree == nil {
+		tree = make(map[string]interface{})
+	}
+	return tree, nil
+}
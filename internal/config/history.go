@@ -0,0 +1,178 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultHistorySize is the number of applied configurations kept for
+// rollback
+const DefaultHistorySize = 20
+
+// Revision is a configuration that was applied at some point. Config and
+// Origins hold resolved secrets; use Config.Redacted before exposing them.
+type Revision struct {
+	Version int
+	Time    time.Time
+	Source  string // Files the configuration was loaded from, or the rollback it came from
+	Hash    string // HMAC-SHA256 of the effective configuration, see hashConfig
+	Config  *Config
+	Origins Origins
+}
+
+// SetHistorySize sets how many revisions are kept. Older revisions are
+// dropped first; n <= 0 restores DefaultHistorySize.
+func (cm *ConfigManager) SetHistorySize(n int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.historySize = n
+	cm.trimHistory()
+}
+
+// History returns the retained revisions, oldest first. The last entry is
+// the active configuration.
+func (cm *ConfigManager) History() []Revision {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	history := make([]Revision, len(cm.history))
+	for i, rev := range cm.history {
+		history[i] = rev.clone()
+	}
+	return history
+}
+
+// Rollback re-applies the configuration of the given revision. It is
+// recorded as a new revision and subscribers are notified as for any other
+// load. The rolled back configuration stays active until the config files
+// change again.
+func (cm *ConfigManager) Rollback(version int) (Revision, error) {
+	cm.applyMu.Lock()
+	defer cm.applyMu.Unlock()
+
+	cm.mu.RLock()
+	var target *Revision
+	for i := range cm.history {
+		if cm.history[i].Version == version {
+			rev := cm.history[i].clone()
+			target = &rev
+		}
+	}
+	cm.mu.RUnlock()
+
+	if target == nil {
+		return Revision{}, fmt.Errorf("config version %d is not in the history", version)
+	}
+
+	cm.apply(target.Config, target.Origins, fmt.Sprintf("rollback to version %d", version))
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.history[len(cm.history)-1].clone(), nil
+}
+
+// record adds cfg to the history unless it is identical to the active
+// revision. It must be called with cm.mu held.
+func (cm *ConfigManager) record(cfg *Config, origins Origins, source string) {
+	hash := hashConfig(cfg)
+	if n := len(cm.history); n > 0 && cm.history[n-1].Hash == hash {
+		return
+	}
+
+	version := 1
+	if n := len(cm.history); n > 0 {
+		version = cm.history[n-1].Version + 1
+	}
+
+	cm.history = append(cm.history, Revision{
+		Version: version,
+		Time:    time.Now(),
+		Source:  source,
+		Hash:    hash,
+		Config:  cfg.Clone(),
+		Origins: origins.clone(),
+	})
+	cm.trimHistory()
+}
+
+// trimHistory drops the oldest revisions beyond the history size. It must
+// be called with cm.mu held.
+func (cm *ConfigManager) trimHistory() {
+	size := cm.historySize
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	if len(cm.history) > size {
+		cm.history = append([]Revision(nil), cm.history[len(cm.history)-size:]...)
+	}
+}
+
+func (r Revision) clone() Revision {
+	r.Config = r.Config.Clone()
+	r.Origins = r.Origins.clone()
+	return r
+}
+
+func (o Origins) clone() Origins {
+	c := make(Origins, len(o))
+	for path, origin := range o {
+		c[path] = origin
+	}
+	return c
+}
+
+// hashKey keys the configuration hashes. The hashed configuration holds
+// resolved secrets, so a plain hash would let anyone who can see it and the
+// redacted configuration check guesses of those secrets offline.
+var hashKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// hashConfig returns the content hash of the effective configuration. It is
+// keyed per process, so hashes are only comparable within one run.
+func hashConfig(cfg *Config) string {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		// Config only holds plain values, so this cannot happen
+		panic(err)
+	}
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// describeSources summarises where a set of file layers came from
+func describeSources(files []Layer) string {
+	var sources []string
+	for _, layer := range files {
+		if layer.Source != "" {
+			sources = append(sources, layer.Source)
+		}
+	}
+
+	switch {
+	case len(sources) > 0:
+		return strings.Join(sources, ", ")
+	case len(files) > 0:
+		return "inline"
+	}
+	return "defaults"
+}
+
+// Tree returns the configuration as nested maps keyed by config path
+// element, e.g. for rendering as JSON with the same keys as the config file
+func (c *Config) Tree() map[string]interface{} {
+	return toTree(reflect.ValueOf(c).Elem())
+}
@@ -0,0 +1,303 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Layer kinds, from lowest to highest precedence
+const (
+	KindDefault = "default"
+	KindFile    = "file"
+	KindEnv     = "env"
+	KindFlag    = "flag"
+)
+
+// Layer is one source of configuration values. Values holds a tree keyed by
+// yaml names, in the same shape as a decoded config file. Layers are merged
+// by Resolve, later layers taking precedence over earlier ones.
+type Layer struct {
+	Kind   string
+	Source string // File path for file layers
+	Values map[string]interface{}
+
+	// Trusted layers may use ${cmd:...} and ${file:...} references. Layers
+	// decoded from remote documents are not trusted: whoever serves them
+	// could otherwise run commands and read files on every host.
+	Trusted bool
+
+	// names records the environment variable or flag that set each path
+	names map[string]string
+
+	// profiles holds the profiles declared by a config file, by name
+	profiles map[string]map[string]interface{}
+}
+
+// Origin describes where an effective configuration value came from
+type Origin struct {
+	Kind   string // One of the Kind* constants
+	Source string // File path, environment variable or flag name
+	Secret bool   // Value was resolved from a secret reference
+	Ref    string // The reference the value was resolved from, if any
+}
+
+// String returns a human readable description of the origin
+func (o Origin) String() string {
+	s := o.Kind
+	if o.Source != "" {
+		s += " " + o.Source
+	}
+	if o.Ref != "" {
+		s += " via " + o.Ref
+	}
+	return s
+}
+
+// Origins maps the dotted path of every effective value to its origin
+type Origins map[string]Origin
+
+// Paths returns the paths in o in lexical order
+func (o Origins) Paths() []string {
+	paths := make([]string, 0, len(o))
+	for path := range o {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// DefaultsLayer returns the built-in defaults as a layer
+func DefaultsLayer() Layer {
+	return Layer{
+		Kind:    KindDefault,
+		Values:  toTree(reflect.ValueOf(Defaults()).Elem()),
+		Trusted: true,
+	}
+}
+
+// NewFileLayer decodes data read from path into a layer, choosing the
+// format from the file extension (see FormatFor)
+func NewFileLayer(path string, data []byte) (Layer, error) {
+	return DecodeLayer(path, FormatFor(path), data)
+}
+
+// DecodeLayer decodes data in the given format into a trusted layer. The
+// data, including any profiles it declares, is checked for unknown keys and
+// type errors so that problems are reported against the file they came
+// from.
+func DecodeLayer(path string, format Format, data []byte) (Layer, error) {
+	tree, err := decodeFormat(format, data)
+	if err != nil {
+		return Layer{}, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	errs := &ValidationError{}
+	profiles := splitProfiles(tree, errs)
+	decodeValue(reflect.ValueOf(&Config{}).Elem(), tree, "", errs)
+	if err := errs.err(); err != nil {
+		return Layer{}, err
+	}
+
+	return Layer{Kind: KindFile, Source: path, Values: tree, Trusted: true, profiles: profiles}, nil
+}
+
+// NewEnvLayer builds a layer from the environment variables named by EnvName
+func NewEnvLayer(lookup func(string) (string, bool)) (Layer, error) {
+	layer := Layer{
+		Kind:    KindEnv,
+		Values:  make(map[string]interface{}),
+		Trusted: true,
+		names:   make(map[string]string),
+	}
+	var errs []string
+
+	walkFields(reflect.ValueOf(&Config{}).Elem(), "", func(path string, field reflect.Value) {
+		name := EnvName(path)
+		value, ok := lookup(name)
+		if !ok {
+			return
+		}
+
+		if err := setFromString(field, value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		layer.set(path, name, field.Interface())
+	})
+
+	if len(errs) > 0 {
+		return Layer{}, fmt.Errorf("invalid environment override: %s", strings.Join(errs, "; "))
+	}
+	return layer, nil
+}
+
+// NewFlagLayer builds a layer from command line flags. bindings maps flag
+// names to the dotted config path they override; only flags explicitly set
+// on the command line are included.
+func NewFlagLayer(flags *pflag.FlagSet, bindings map[string]string) (Layer, error) {
+	layer := Layer{
+		Kind:    KindFlag,
+		Values:  make(map[string]interface{}),
+		Trusted: true,
+		names:   make(map[string]string),
+	}
+
+	fields := make(map[string]reflect.Value)
+	walkFields(reflect.ValueOf(&Config{}).Elem(), "", func(path string, field reflect.Value) {
+		fields[path] = field
+	})
+
+	for name, path := range bindings {
+		field, ok := fields[path]
+		if !ok {
+			return Layer{}, fmt.Errorf("flag --%s is bound to unknown config path %q", name, path)
+		}
+
+		flag := flags.Lookup(name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+
+		value := flag.Value.String()
+		if sv, ok := flag.Value.(pflag.SliceValue); ok {
+			value = strings.Join(sv.GetSlice(), ",")
+		}
+
+		if err := setFromString(field, value); err != nil {
+			return Layer{}, fmt.Errorf("invalid value for --%s: %w", name, err)
+		}
+		layer.set(path, "--"+name, field.Interface())
+	}
+
+	return layer, nil
+}
+
+// set stores value at the dotted path, recording the setting name
+func (l *Layer) set(path, name string, value interface{}) {
+	keys := strings.Split(path, ".")
+	node := l.Values
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := node[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			node[key] = child
+		}
+		node = child
+	}
+	node[keys[len(keys)-1]] = value
+
+	if l.names != nil {
+		l.names[path] = name
+	}
+}
+
+// origin returns the origin of the value at path within the layer
+func (l *Layer) origin(path string) Origin {
+	if name, ok := l.names[path]; ok {
+		return Origin{Kind: l.Kind, Source: name}
+	}
+	return Origin{Kind: l.Kind, Source: l.Source}
+}
+
+// Resolve merges layers in order and decodes the result into a validated
+// Config. Mappings are merged key by key; scalars and lists from later
+// layers replace earlier ones. Secret references in string values are
+// resolved after merging; layers that are not trusted may only use
+// ${env:...} references. The returned Origins records which layer
+// supplied each effective value.
+func Resolve(layers ...Layer) (*Config, Origins, error) {
+	return resolve(layers, true)
+}
+
+// resolve implements Resolve. Without onHost the layers are checked for use
+// on another machine, as by Parse: secret references are left unresolved
+// and validation skips checks against the local file system.
+func resolve(layers []Layer, onHost bool) (*Config, Origins, error) {
+	errs := &ValidationError{}
+	for i := range layers {
+		if !layers[i].Trusted {
+			rejectLocalRefs(layers[i].Values, "", layers[i].Source, errs)
+		}
+	}
+	if err := errs.err(); err != nil {
+		return nil, nil, err
+	}
+
+	merged := make(map[string]interface{})
+	origins := make(Origins)
+
+	for i := range layers {
+		mergeTree(merged, layers[i].Values, "", &layers[i], origins)
+	}
+
+	if onHost {
+		resolveSecrets(merged, "", origins, errs)
+		if err := errs.err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cfg := &Config{}
+	if err := decodeTree(merged, cfg); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.validate(onHost); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, origins, nil
+}
+
+// mergeTree deep-merges src into dst, recording the origin of every leaf
+func mergeTree(dst, src map[string]interface{}, prefix string, layer *Layer, origins Origins) {
+	for key, value := range src {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if value == nil {
+			continue
+		}
+
+		if srcMap, ok := value.(map[string]interface{}); ok {
+			dstMap, ok := dst[key].(map[string]interface{})
+			if !ok {
+				dstMap = make(map[string]interface{})
+				dst[key] = dstMap
+			}
+			mergeTree(dstMap, srcMap, path, layer, origins)
+			continue
+		}
+
+		dst[key] = value
+		origins[path] = layer.origin(path)
+	}
+}
+
+// toTree converts a struct into a tree keyed by yaml names
+func toTree(v reflect.Value) map[string]interface{} {
+	tree := make(map[string]interface{})
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name := yamlName(t.Field(i))
+		if name == "" {
+			continue
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct && !isTextType(field) {
+			tree[name] = toTree(field)
+			continue
+		}
+		tree[name] = field.Interface()
+	}
+
+	return tree
+}
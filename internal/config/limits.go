@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BodyLimit caps the request body size for paths under a prefix. It is
+// configured as "PREFIX=SIZE", e.g. "/api/auth/token=64KiB".
+type BodyLimit struct {
+	Prefix string
+	Size   ByteSize
+}
+
+// String returns l in its configured form
+func (l BodyLimit) String() string {
+	return l.Prefix + "=" + l.Size.String()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (l BodyLimit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (l *BodyLimit) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+
+	i := strings.LastIndex(s, "=")
+	if i < 0 || !strings.HasPrefix(s, "/") {
+		return fmt.Errorf("invalid body limit %q (want e.g. \"/api/upload=100MiB\")", s)
+	}
+
+	var size ByteSize
+	if err := size.UnmarshalText([]byte(s[i+1:])); err != nil {
+		return err
+	}
+	if size < 0 {
+		return fmt.Errorf("body limit %q must not be negative", s)
+	}
+
+	*l = BodyLimit{Prefix: strings.TrimSpace(s[:i]), Size: size}
+	return nil
+}
+
+// BodyLimitFor returns the largest request body accepted for path: the
+// size of the body limit with the longest matching prefix, or
+// max_body_size if none matches. 0 means no limit.
+func (s ServerConfig) BodyLimitFor(path string) ByteSize {
+	limit, matched := s.MaxBodySize, -1
+	for _, l := range s.BodyLimits {
+		if strings.HasPrefix(path, l.Prefix) && len(l.Prefix) > matched {
+			limit, matched = l.Size, len(l.Prefix)
+		}
+	}
+	return limit
+}
@@ -1,81 +1,239 @@
 package config
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"os"
-
-	"github.com/fsnotify/fsnotify"
+	"log/slog"
+	"sync"
+	"time"
 )
 
-// Loader handles configuration file loading and hot-reload
+// reloadDebounce is how long the loader waits for change notifications to
+// settle before reloading, so that a burst of writes results in a single
+// reload
+const reloadDebounce = 100 * time.Millisecond
+
+// maxReloadDelay caps how long a steady stream of change notifications can
+// hold off a reload
+const maxReloadDelay = time.Second
+
+// Loader handles configuration loading and hot-reload. A loader reads
+// documents from one or more sources, e.g. a local file with conf.d
+// fragments and a central HTTP endpoint, and merges them in order: later
+// sources take precedence over earlier ones.
+//
+// Sources notify the loader when their content may have changed. The
+// notifications are debounced and the configuration is only reloaded when
+// the content of the documents actually changed.
 type Loader struct {
-	filepath string
-	watcher  *fsnotify.Watcher
-	stopCh   chan struct{}
+	sources []ConfigSource
+	file    *FileSource
+	stopCh  chan struct{}
+	changed chan struct{}
+	stops   []func()
+
+	mu       sync.RWMutex
+	lastErr  error
+	lastHash string
 }
 
-// NewLoader creates a new configuration loader
+// NewLoader creates a configuration loader for a local config file
 func NewLoader(filepath string) (*Loader, error) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
-	}
+	file := NewFileSource(filepath)
+	loader := NewSourceLoader(file)
+	loader.file = file
+	return loader, nil
+}
 
+// NewSourceLoader creates a configuration loader reading the given sources
+// in increasing order of precedence
+func NewSourceLoader(sources ...ConfigSource) *Loader {
 	return &Loader{
-		filepath: filepath,
-		watcher:  watcher,
-		stopCh:   make(chan struct{}),
-	}, nil
+		sources: sources,
+		stopCh:  make(chan struct{}),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// SetFormat sets the format of the config file of a loader created with
+// NewLoader. See FileSource.SetFormat.
+func (l *Loader) SetFormat(format Format) {
+	if l.file != nil {
+		l.file.SetFormat(format)
+	}
+}
+
+// SetConfDir sets the conf.d directory of a loader created with NewLoader.
+// See FileSource.SetConfDir. It must be called before Load and StartWatching.
+func (l *Loader) SetConfDir(dir string) {
+	if l.file != nil {
+		l.file.SetConfDir(dir)
+	}
 }
 
-// Load loads the configuration from file
+// Load loads the configuration from the sources. If a source cannot be
+// read or the result fails validation the previously loaded configuration
+// stays active and the error is also available from LastError.
 func (l *Loader) Load() error {
-	data, err := os.ReadFile(l.filepath)
+	docs, err := l.read()
+	if err == nil {
+		err = l.apply(docs)
+	}
+
+	l.mu.Lock()
+	l.lastErr = err
+	l.lastHash = hashDocuments(docs)
+	l.mu.Unlock()
+
+	return err
+}
+
+// reload is called by the watcher. It reloads the configuration only if
+// the content of the documents changed since the last attempt.
+func (l *Loader) reload() {
+	docs, err := l.read()
+	hash := hashDocuments(docs)
+
+	l.mu.Lock()
+	unchanged := err == nil && hash == l.lastHash
+	l.lastHash = hash
+	l.mu.Unlock()
+
+	if unchanged {
+		return
+	}
+
+	if err == nil {
+		err = l.apply(docs)
+	}
+
+	l.mu.Lock()
+	l.lastErr = err
+	l.mu.Unlock()
+
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		slog.Error("Config reload rejected, keeping previous configuration", "error", err)
+		return
 	}
 
-	return GetInstance().Load(data)
+	names := make([]string, len(docs))
+	for i, doc := range docs {
+		names[i] = doc.Name
+	}
+	slog.Info("Config reloaded", "sources", names)
 }
 
-// StartWatching starts watching for configuration file changes
+// read reads the documents of every source in order
+func (l *Loader) read() ([]Document, error) {
+	var docs []Document
+	for _, source := range l.sources {
+		sourceDocs, err := source.Read(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source.Name(), err)
+		}
+		docs = append(docs, sourceDocs...)
+	}
+	return docs, nil
+}
+
+// apply parses the documents and loads them into the config manager
+func (l *Loader) apply(docs []Document) error {
+	layers := make([]Layer, 0, len(docs))
+	for _, doc := range docs {
+		layer, err := DecodeLayer(doc.Name, doc.Format, doc.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", doc.Name, err)
+		}
+		layer.Trusted = !doc.Remote
+		layers = append(layers, layer)
+	}
+
+	return GetInstance().LoadLayers(layers...)
+}
+
+// hashDocuments returns a digest of the names and contents of docs
+func hashDocuments(docs []Document) string {
+	h := sha256.New()
+	for _, doc := range docs {
+		fmt.Fprintf(h, "%s\x00%s\x00%d\x00", doc.Name, doc.Format, len(doc.Data))
+		h.Write(doc.Data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LastError returns the error from the most recent load attempt, or nil if
+// it succeeded
+func (l *Loader) LastError() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lastErr
+}
+
+// StartWatching starts watching every source for changes
 func (l *Loader) StartWatching() error {
-	if err := l.watcher.Add(l.filepath); err != nil {
-		return fmt.Errorf("failed to watch config file: %w", err)
+	for _, source := range l.sources {
+		stop, err := source.Watch(l.notify)
+		if err != nil {
+			l.stopWatches()
+			return fmt.Errorf("%s: %w", source.Name(), err)
+		}
+		l.stops = append(l.stops, stop)
 	}
 
 	go l.watchLoop()
 	return nil
 }
 
+// notify records that a source may have changed
+func (l *Loader) notify() {
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+}
+
 func (l *Loader) watchLoop() {
+	var debounce <-chan time.Time
+	var deadline time.Time
+
 	for {
 		select {
 		case <-l.stopCh:
 			return
-		case event, ok := <-l.watcher.Events:
-			if !ok {
-				return
-			}
 
-			// Reload config on write or create events
-			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-				if err := l.Load(); err != nil {
-					fmt.Printf("Error reloading config: %v\n", err)
-				}
+		case <-l.changed:
+			// Every notification restarts the debounce timer, up to
+			// maxReloadDelay after the first one; the content hash
+			// decides whether to reload
+			now := time.Now()
+			if debounce == nil {
+				deadline = now.Add(maxReloadDelay)
 			}
-
-		case err, ok := <-l.watcher.Errors:
-			if !ok {
-				return
+			wait := reloadDebounce
+			if left := deadline.Sub(now); left < wait {
+				wait = left
 			}
-			fmt.Printf("Watcher error: %v\n", err)
+			debounce = time.After(wait)
+
+		case <-debounce:
+			debounce = nil
+			l.reload()
 		}
 	}
 }
 
+func (l *Loader) stopWatches() {
+	for _, stop := range l.stops {
+		stop()
+	}
+	l.stops = nil
+}
+
 // Stop stops watching for changes
 func (l *Loader) Stop() error {
 	close(l.stopCh)
-	return l.watcher.Close()
+	l.stopWatches()
+	return nil
 }
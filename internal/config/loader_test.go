@@ -0,0 +1,314 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// waitFor polls cond until it holds or the timeout expires
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestLoaderMergesConfDir(t *testing.T) {
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	base := filepath.Join(dir, "config.yaml")
+	writeFile(t, base, "server:\n  host: base.example\n  port: 8000\napi:\n  allowed_origins: [a, b]\n")
+	writeFile(t, filepath.Join(confDir, "20-port.yaml"), "server:\n  port: 8020\n")
+	writeFile(t, filepath.Join(confDir, "10-port.yaml"), "server:\n  port: 8010\napi:\n  allowed_origins: [c]\n")
+	writeFile(t, filepath.Join(confDir, "notes.txt"), "server: nonsense\n")
+
+	loader, err := NewLoader(base)
+	if err != nil {
+		t.Fatalf("NewLoader failed: %v", err)
+	}
+	defer loader.Stop()
+	loader.SetConfDir(confDir)
+
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	cfg := GetInstance().Get()
+	if cfg.Server.Host != "base.example" {
+		t.Fatalf("Server.Host = %q, want value from base file", cfg.Server.Host)
+	}
+	if cfg.Server.Port != 8020 {
+		t.Fatalf("Server.Port = %d, want 8020 from the last fragment", cfg.Server.Port)
+	}
+	if len(cfg.API.AllowedOrigins) != 1 || cfg.API.AllowedOrigins[0] != "c" {
+		t.Fatalf("API.AllowedOrigins = %v, want lists to be replaced", cfg.API.AllowedOrigins)
+	}
+
+	if err := loader.StartWatching(); err != nil {
+		t.Fatalf("StartWatching failed: %v", err)
+	}
+
+	// Adding a fragment triggers a reload
+	writeFile(t, filepath.Join(confDir, "30-port.yaml"), "server:\n  port: 8030\n")
+	waitFor(t, "new fragment to be applied", func() bool {
+		return GetInstance().GetServer().Port == 8030
+	})
+
+	// Removing it falls back to the remaining fragments
+	if err := os.Remove(filepath.Join(confDir, "30-port.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "removed fragment to be dropped", func() bool {
+		return GetInstance().GetServer().Port == 8020
+	})
+}
+
+func startLoader(t *testing.T, path string) *Loader {
+	t.Helper()
+
+	loader, err := NewLoader(path)
+	if err != nil {
+		t.Fatalf("NewLoader failed: %v", err)
+	}
+	t.Cleanup(func() { loader.Stop() })
+
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := loader.StartWatching(); err != nil {
+		t.Fatalf("StartWatching failed: %v", err)
+	}
+	return loader
+}
+
+func portIs(port int) func() bool {
+	return func() bool { return GetInstance().GetServer().Port == port }
+}
+
+func TestLoaderFollowsAtomicSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "server:\n  port: 8101\n")
+	startLoader(t, path)
+
+	// Editors commonly write a temporary file and rename it over the original
+	for _, port := range []string{"8102", "8103"} {
+		tmp := filepath.Join(dir, ".config.yaml.tmp")
+		writeFile(t, tmp, "server:\n  port: "+port+"\n")
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "renamed file to be loaded", portIs(8103))
+}
+
+func TestLoaderSurvivesDeleteAndRecreate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "server:\n  port: 8201\n")
+	loader := startLoader(t, path)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "deletion to be reported", func() bool { return loader.LastError() != nil })
+	if port := GetInstance().GetServer().Port; port != 8201 {
+		t.Fatalf("Server.Port = %d, want last good value 8201", port)
+	}
+
+	writeFile(t, path, "server:\n  port: 8202\n")
+	waitFor(t, "recreated file to be loaded", portIs(8202))
+	if err := loader.LastError(); err != nil {
+		t.Fatalf("LastError = %v, want nil after recovery", err)
+	}
+}
+
+func TestLoaderFollowsConfigMapSwap(t *testing.T) {
+	dir := t.TempDir()
+
+	// Kubernetes mounts ConfigMaps as dir/config.yaml -> ..data/config.yaml
+	// with ..data -> ..<timestamp>, and updates by swapping the ..data link
+	mkVersion := func(name, port string) {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(dir, name, "config.yaml"), "server:\n  port: "+port+"\n")
+	}
+	mkVersion("..v1", "8301")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), path); err != nil {
+		t.Fatal(err)
+	}
+	startLoader(t, path)
+
+	for i, port := range []string{"8302", "8303"} {
+		version := "..v" + port
+		mkVersion(version, port)
+		tmpLink := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(version, tmpLink); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmpLink, filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, "swapped ConfigMap to be loaded", portIs(8302+i))
+	}
+}
+
+func TestLoaderDebouncesBursts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "server:\n  port: 8401\n")
+	startLoader(t, path)
+
+	var reloads int32
+	GetInstance().Watch(func(cfg *Config) {
+		if cfg.Server.Port >= 8400 && cfg.Server.Port < 8500 {
+			atomic.AddInt32(&reloads, 1)
+		}
+	})
+
+	for port := 8402; port <= 8410; port++ {
+		writeFile(t, path, "server:\n  port: "+strconv.Itoa(port)+"\n")
+	}
+	waitFor(t, "burst to be loaded", portIs(8410))
+
+	// Rewriting identical content must not trigger another reload
+	writeFile(t, path, "server:\n  port: 8410\n")
+	time.Sleep(3 * reloadDebounce)
+
+	if n := atomic.LoadInt32(&reloads); n != 1 {
+		t.Fatalf("got %d reloads, want 1", n)
+	}
+}
+
+func TestFileSourceIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "server:\n  port: 8501\n")
+
+	var changes int32
+	stop, err := NewFileSource(path).Watch(func() { atomic.AddInt32(&changes, 1) })
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer stop()
+
+	// A log written next to the config file must not count as a change
+	for i := 0; i < 5; i++ {
+		writeFile(t, filepath.Join(dir, "stroganoff.log"), strconv.Itoa(i))
+	}
+	time.Sleep(3 * reloadDebounce)
+	if n := atomic.LoadInt32(&changes); n != 0 {
+		t.Fatalf("got %d changes for an unrelated file, want 0", n)
+	}
+
+	writeFile(t, path, "server:\n  port: 8502\n")
+	waitFor(t, "config change to be reported", func() bool { return atomic.LoadInt32(&changes) > 0 })
+}
+
+func TestLoaderReloadsDuringSteadyNotifications(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "server:\n  port: 8601\n")
+	loader := startLoader(t, path)
+
+	// Notifications arriving faster than the debounce delay must not hold
+	// off the reload forever
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(reloadDebounce / 4):
+				loader.notify()
+			}
+		}
+	}()
+
+	writeFile(t, path, "server:\n  port: 8602\n")
+	waitFor(t, "change to be loaded", portIs(8602))
+}
+
+func TestLoaderReloadsTOML(t *testing.T) {
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "config.toml")
+	writeFile(t, path, "[server]\nport = 8501\n")
+	writeFile(t, filepath.Join(confDir, "10-api.json"), `{"api": {"rate_limit": 7}}`)
+
+	loader, err := NewLoader(path)
+	if err != nil {
+		t.Fatalf("NewLoader failed: %v", err)
+	}
+	defer loader.Stop()
+	loader.SetConfDir(confDir)
+
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if limit := GetInstance().GetAPI().RateLimit; limit != 7 {
+		t.Fatalf("API.RateLimit = %d, want 7 from the JSON fragment", limit)
+	}
+	if err := loader.StartWatching(); err != nil {
+		t.Fatalf("StartWatching failed: %v", err)
+	}
+
+	writeFile(t, path, "[server]\nport = 8502\n")
+	waitFor(t, "TOML change to be loaded", portIs(8502))
+
+	// Invalid edits are rejected just like for YAML
+	writeFile(t, path, "[server]\nport = 0\n")
+	waitFor(t, "invalid TOML to be rejected", func() bool { return loader.LastError() != nil })
+	if port := GetInstance().GetServer().Port; port != 8502 {
+		t.Fatalf("Server.Port = %d, want last good value 8502", port)
+	}
+}
+
+func TestLoaderExplicitFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	writeFile(t, path, `{"server": {"port": 8601}}`)
+
+	loader, err := NewLoader(path)
+	if err != nil {
+		t.Fatalf("NewLoader failed: %v", err)
+	}
+	defer loader.Stop()
+	loader.SetFormat(FormatJSON)
+
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if port := GetInstance().GetServer().Port; port != 8601 {
+		t.Fatalf("Server.Port = %d, want 8601", port)
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldChange describes a single configuration value that changed
+type FieldChange struct {
+	Path    string // Dotted yaml path, e.g. "api.rate_limit"
+	Section string // Top-level section, e.g. "api"
+	Old     interface{}
+	New     interface{}
+}
+
+// Change is delivered to subscribers when a new configuration is applied.
+// Old and New are snapshots owned by the subscriber: they are never
+// modified by the config manager and changes made to them have no effect.
+type Change struct {
+	Old  *Config
+	New  *Config
+	Diff []FieldChange
+}
+
+// Sections returns the top-level sections that changed, in diff order
+func (c Change) Sections() []string {
+	var sections []string
+	seen := make(map[string]bool)
+	for _, fc := range c.Diff {
+		if !seen[fc.Section] {
+			seen[fc.Section] = true
+			sections = append(sections, fc.Section)
+		}
+	}
+	return sections
+}
+
+// Changed reports whether the value at path, or any value below it when
+// path names a section, changed
+func (c Change) Changed(path string) bool {
+	for _, fc := range c.Diff {
+		if fc.Path == path || strings.HasPrefix(fc.Path, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the values that differ between old and new, in field order
+func Diff(old, new *Config) []FieldChange {
+	oldValues := make(map[string]interface{})
+	walkFields(reflect.ValueOf(old).Elem(), "", func(path string, field reflect.Value) {
+		oldValues[path] = field.Interface()
+	})
+
+	var diff []FieldChange
+	walkFields(reflect.ValueOf(new).Elem(), "", func(path string, field reflect.Value) {
+		newValue := field.Interface()
+		if reflect.DeepEqual(oldValues[path], newValue) {
+			return
+		}
+		diff = append(diff, FieldChange{
+			Path:    path,
+			Section: strings.SplitN(path, ".", 2)[0],
+			Old:     oldValues[path],
+			New:     newValue,
+		})
+	})
+	return diff
+}
+
+// subscription is a registered change callback
+type subscription struct {
+	fn       func(Change)
+	sections map[string]bool
+}
+
+// wants reports whether the subscription is interested in change
+func (s *subscription) wants(change Change) bool {
+	if len(s.sections) == 0 {
+		return true
+	}
+	for _, section := range change.Sections() {
+		if s.sections[section] {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe registers fn to be called whenever an applied configuration
+// differs from the previous one. If sections are given (e.g. "api"), fn is
+// only called when one of them changed.
+//
+// Subscribers are called synchronously, in registration order, after the
+// new configuration has taken effect and without any config manager lock
+// held, so they may call Get. They must not load configuration themselves.
+// The returned function removes the subscription.
+func (cm *ConfigManager) Subscribe(fn func(Change), sections ...string) (unsubscribe func()) {
+	sub := &subscription{fn: fn, sections: make(map[string]bool)}
+	for _, section := range sections {
+		sub.sections[section] = true
+	}
+
+	cm.mu.Lock()
+	cm.subscribers = append(cm.subscribers, sub)
+	cm.mu.Unlock()
+
+	return func() {
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+		for i, s := range cm.subscribers {
+			if s == sub {
+				cm.subscribers = append(cm.subscribers[:i:i], cm.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// apply makes cfg the current configuration, records it in the history
+// and notifies subscribers. It must be called with cm.applyMu held so that
+// notifications for successive changes are delivered in order.
+func (cm *ConfigManager) apply(cfg *Config, origins Origins, source string) {
+	cm.mu.Lock()
+	old := cm.config
+	cm.config = cfg
+	cm.origins = origins
+	cm.record(cfg, origins, source)
+	subscribers := append([]*subscription(nil), cm.subscribers...)
+	cm.mu.Unlock()
+
+	diff := Diff(old, cfg)
+	if len(diff) == 0 {
+		return
+	}
+
+	for _, sub := range subscribers {
+		change := Change{Old: old.Clone(), New: cfg.Clone(), Diff: diff}
+		if sub.wants(change) {
+			sub.fn(change)
+		}
+	}
+}
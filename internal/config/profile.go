@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// ProfilesKey is the top-level key under which config files declare
+// profiles: named sets of values overlaid on the rest of the file, e.g.
+//
+//	server:
+//	  host: 0.0.0.0
+//	profiles:
+//	  dev:
+//	    logging:
+//	      level: debug
+//	  prod:
+//	    server:
+//	      port: 443
+const ProfilesKey = "profiles"
+
+// ProfileEnv is the environment variable selecting a profile when none is
+// set with SetProfile
+const ProfileEnv = "STROGANOFF_PROFILE"
+
+// splitProfiles removes the profiles from a decoded config file tree and
+// checks each of them for unknown keys and type errors
+func splitProfiles(tree map[string]interface{}, errs *ValidationError) map[string]map[string]interface{} {
+	raw, ok := tree[ProfilesKey]
+	if !ok {
+		return nil
+	}
+	delete(tree, ProfilesKey)
+
+	if raw == nil {
+		return nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		errs.add(ProfilesKey, raw, "expected a mapping")
+		return nil
+	}
+
+	profiles := make(map[string]map[string]interface{})
+	for _, name := range sortedKeys(m) {
+		path := ProfilesKey + "." + name
+
+		values, ok := m[name].(map[string]interface{})
+		if !ok && m[name] != nil {
+			errs.add(path, m[name], "expected a mapping")
+			continue
+		}
+		if values == nil {
+			values = make(map[string]interface{})
+		}
+
+		decodeValue(reflect.ValueOf(&Config{}).Elem(), values, path, errs)
+		profiles[name] = values
+	}
+	return profiles
+}
+
+// Profiles returns the names of the profiles declared by the layer
+func (l Layer) Profiles() []string {
+	names := make([]string, 0, len(l.profiles))
+	for name := range l.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectProfile inserts the values of the named profile after each file
+// layer that declares it. An empty name selects no profile. It is an error
+// to select a profile that none of the files declares.
+func selectProfile(files []Layer, name string) ([]Layer, error) {
+	if name == "" {
+		return files, nil
+	}
+
+	layers := make([]Layer, 0, len(files)*2)
+	found := false
+	for _, layer := range files {
+		layers = append(layers, layer)
+
+		values, ok := layer.profiles[name]
+		if !ok {
+			continue
+		}
+		found = true
+
+		source := "profile " + name
+		if layer.Source != "" {
+			source = fmt.Sprintf("%s (%s)", layer.Source, source)
+		}
+		layers = append(layers, Layer{Kind: layer.Kind, Source: source, Values: values, Trusted: layer.Trusted})
+	}
+
+	if !found {
+		return nil, fmt.Errorf("profile %q is not defined in any config file", name)
+	}
+	return layers, nil
+}
+
+// SetProfile selects the profile applied on every subsequent load. The
+// empty string falls back to the STROGANOFF_PROFILE environment variable.
+func (cm *ConfigManager) SetProfile(name string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.profile = name
+}
+
+// Profile returns the name of the selected profile, or "" if none is
+func (cm *ConfigManager) Profile() string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	if cm.profile != "" {
+		return cm.profile
+	}
+	return os.Getenv(ProfileEnv)
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is how often remote sources are checked for changes
+// unless configured otherwise
+const DefaultPollInterval = 30 * time.Second
+
+// maxRemoteConfigSize bounds the size of a document fetched from a remote
+// source
+const maxRemoteConfigSize = 10 << 20
+
+// HTTPSource reads a single configuration document from an HTTP endpoint,
+// so that a fleet of servers can pull their configuration centrally. The
+// endpoint is polled with conditional requests: when it returns an ETag,
+// unchanged documents cost a 304 response.
+type HTTPSource struct {
+	url      string
+	format   Format
+	interval time.Duration
+	client   *http.Client
+	header   http.Header
+
+	mu   sync.Mutex
+	etag string
+	doc  *Document
+}
+
+// NewHTTPSource creates a source for the document at url, polled every
+// interval (DefaultPollInterval if zero)
+func NewHTTPSource(url string, interval time.Duration) *HTTPSource {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	return &HTTPSource{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		header:   make(http.Header),
+	}
+}
+
+// SetFormat sets the format of the document. By default it is taken from
+// the Content-Type of the response, falling back to the URL extension.
+func (s *HTTPSource) SetFormat(format Format) {
+	s.format = format
+}
+
+// SetHeader sets a header sent with every request, e.g. Authorization
+func (s *HTTPSource) SetHeader(key, value string) {
+	s.header.Set(key, value)
+}
+
+// Name implements ConfigSource
+func (s *HTTPSource) Name() string {
+	return s.url
+}
+
+// Read implements ConfigSource
+func (s *HTTPSource) Read(ctx context.Context) ([]Document, error) {
+	if _, err := s.poll(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []Document{*s.doc}, nil
+}
+
+// Watch implements ConfigSource by polling the endpoint
+func (s *HTTPSource) Watch(changed func()) (func(), error) {
+	return startPolling(s.url, s.interval, s.poll, changed), nil
+}
+
+// poll fetches the document unless the cached copy is still current, and
+// reports whether it changed
+func (s *HTTPSource) poll(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return false, err
+	}
+	for key, values := range s.header {
+		req.Header[key] = values
+	}
+
+	s.mu.Lock()
+	etag := s.etag
+	cached := s.doc != nil
+	s.mu.Unlock()
+	if etag != "" && cached {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to fetch config: server returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	if len(data) > maxRemoteConfigSize {
+		return false, fmt.Errorf("config document exceeds %d bytes", maxRemoteConfigSize)
+	}
+
+	doc := &Document{Name: s.url, Format: s.responseFormat(resp), Data: data, Remote: true}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.doc == nil || s.doc.Format != doc.Format || !bytes.Equal(s.doc.Data, doc.Data)
+	s.doc = doc
+	s.etag = resp.Header.Get("ETag")
+	return changed, nil
+}
+
+// responseFormat returns the format of a response body
+func (s *HTTPSource) responseFormat(resp *http.Response) Format {
+	if s.format != "" {
+		return s.format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		return FormatJSON
+	case "application/toml":
+		return FormatTOML
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		return FormatYAML
+	}
+
+	if u, err := url.Parse(s.url); err == nil {
+		return FormatFor(u.Path)
+	}
+	return FormatYAML
+}
+
+// KVStore is a key-value backend such as Consul, etcd or a database table.
+// Adapters only need to fetch a single key.
+type KVStore interface {
+	// Get returns the value stored at key and an opaque version that
+	// changes whenever the value does, e.g. a modify index or revision
+	Get(ctx context.Context, key string) (value []byte, version string, err error)
+}
+
+// KVSource reads a single configuration document stored under a key of a
+// KVStore, polling the store's version to detect changes
+type KVSource struct {
+	store    KVStore
+	key      string
+	format   Format
+	interval time.Duration
+
+	mu      sync.Mutex
+	version string
+	doc     *Document
+}
+
+// NewKVSource creates a source for the document stored at key, polled
+// every interval (DefaultPollInterval if zero). The format is taken from
+// the key's extension unless set with SetFormat.
+func NewKVSource(store KVStore, key string, interval time.Duration) *KVSource {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	return &KVSource{
+		store:    store,
+		key:      key,
+		format:   FormatFor(key),
+		interval: interval,
+	}
+}
+
+// SetFormat sets the format of the document
+func (s *KVSource) SetFormat(format Format) {
+	s.format = format
+}
+
+// Name implements ConfigSource
+func (s *KVSource) Name() string {
+	return "kv:" + s.key
+}
+
+// Read implements ConfigSource
+func (s *KVSource) Read(ctx context.Context) ([]Document, error) {
+	if _, err := s.poll(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []Document{*s.doc}, nil
+}
+
+// Watch implements ConfigSource by polling the store
+func (s *KVSource) Watch(changed func()) (func(), error) {
+	return startPolling(s.Name(), s.interval, s.poll, changed), nil
+}
+
+// poll fetches the document and reports whether its version changed
+func (s *KVSource) poll(ctx context.Context) (bool, error) {
+	data, version, err := s.store.Get(ctx, s.key)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch config: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.doc == nil || version != s.version
+	s.doc = &Document{Name: s.Name(), Format: s.format, Data: data, Remote: true}
+	s.version = version
+	return changed, nil
+}
+
+// startPolling calls poll every interval until the returned function is
+// called, and changed whenever poll reports a change
+func startPolling(name string, interval time.Duration, poll func(context.Context) (bool, error), changed func()) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				updated, err := poll(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						slog.Warn("Failed to poll config source", "source", name, "error", err)
+					}
+					continue
+				}
+				if updated {
+					changed()
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SchemaDialect is the JSON Schema version produced by Schema
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// unitTypes are text types that also accept plain numbers
+var unitTypes = map[reflect.Type]bool{
+	reflect.TypeOf(Duration(0)):        true,
+	reflect.TypeOf(ByteSize(0)):        true,
+	reflect.TypeOf(TLSVersionName("")): true,
+}
+
+// Schema returns a JSON Schema describing config files. It is generated
+// from the Config struct: property names come from the yaml tags, defaults
+// from Defaults, and descriptions and constraints from the desc, enum,
+// minimum and maximum tags. Validate checks configurations against the
+// same schema, so editors and CI lint files exactly as the server would.
+func Schema() map[string]interface{} {
+	schema := objectSchema(reflect.ValueOf(Defaults()).Elem())
+	schema["properties"].(map[string]interface{})[ProfilesKey] = map[string]interface{}{
+		"type":                 "object",
+		"description":          "Named overlays applied on top of this file, selected with --profile or " + ProfileEnv,
+		"additionalProperties": objectSchema(reflect.ValueOf(Defaults()).Elem()),
+	}
+	schema["$schema"] = SchemaDialect
+	schema["title"] = "stroganoff configuration"
+	return schema
+}
+
+// objectSchema describes a config struct. defaults holds its default value.
+func objectSchema(defaults reflect.Value) map[string]interface{} {
+	properties := make(map[string]interface{})
+	t := defaults.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := yamlName(sf)
+		if name == "" {
+			continue
+		}
+		properties[name] = fieldSchema(sf, defaults.Field(i))
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// fieldSchema describes a single config field
+func fieldSchema(sf reflect.StructField, defaults reflect.Value) map[string]interface{} {
+	var schema map[string]interface{}
+	if defaults.Kind() == reflect.Struct && !isTextType(defaults) {
+		schema = objectSchema(defaults)
+	} else {
+		schema = map[string]interface{}{
+			"type":    jsonType(sf.Type),
+			"default": defaults.Interface(),
+		}
+		if unitTypes[sf.Type] {
+			schema["type"] = []string{"string", "number"}
+		}
+		if sf.Type.Kind() == reflect.Slice {
+			schema["items"] = map[string]interface{}{"type": jsonType(sf.Type.Elem())}
+		}
+	}
+
+	if desc := sf.Tag.Get("desc"); desc != "" {
+		schema["description"] = desc
+	}
+	if enum := sf.Tag.Get("enum"); enum != "" {
+		schema["enum"] = strings.Split(enum, ",")
+		if unitTypes[sf.Type] {
+			schema["enum"] = withNumbers(schema["enum"].([]string))
+		}
+	}
+	for _, keyword := range []string{"minimum", "maximum"} {
+		if limit := sf.Tag.Get(keyword); limit != "" {
+			n, err := strconv.ParseFloat(limit, 64)
+			if err != nil {
+				panic(fmt.Sprintf("config: invalid %s tag on %s: %v", keyword, sf.Name, err))
+			}
+			schema[keyword] = n
+		}
+	}
+
+	return schema
+}
+
+// withNumbers returns the values of a string enum followed by those that
+// are numbers as numbers, for types that accept them unquoted
+func withNumbers(values []string) []interface{} {
+	enum := make([]interface{}, 0, 2*len(values))
+	for _, value := range values {
+		enum = append(enum, value)
+	}
+	for _, value := range values {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			enum = append(enum, n)
+		}
+	}
+	return enum
+}
+
+// jsonType returns the JSON Schema type of values of t
+func jsonType(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return "string"
+}
+
+// validateSchema checks value against the subset of JSON Schema produced
+// by Schema and adds a FieldError for every violation
+func validateSchema(schema map[string]interface{}, value interface{}, path string, errs *ValidationError) {
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		tree, _ := value.(map[string]interface{})
+		for _, key := range sortedKeys(tree) {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+
+			property, known := properties[key].(map[string]interface{})
+			if !known {
+				if schema["additionalProperties"] == false {
+					errs.add(fieldPath, nil, "unknown field")
+				}
+				continue
+			}
+			validateSchema(property, tree[key], fieldPath, errs)
+		}
+		return
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		list := reflect.ValueOf(value)
+		if list.Kind() == reflect.Slice {
+			for i := 0; i < list.Len(); i++ {
+				validateSchema(items, list.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	}
+
+	if enum := enumStrings(schema); enum != nil {
+		// Decoded values are strings; numbers in the enum only describe
+		// how they may be written
+		if v := reflect.ValueOf(value); v.Kind() != reflect.String || !contains(enum, v.String()) {
+			errs.add(path, value, "must be one of %s", strings.Join(enum, ", "))
+		}
+	}
+
+	min, hasMin := schema["minimum"].(float64)
+	max, hasMax := schema["maximum"].(float64)
+	if !hasMin && !hasMax {
+		return
+	}
+	n, ok := numericValue(value)
+	if !ok {
+		return
+	}
+
+	switch {
+	case hasMin && hasMax && (n < min || n > max):
+		errs.add(path, value, "must be between %v and %v", min, max)
+	case hasMin && !hasMax && n < min && min == 0:
+		errs.add(path, value, "must not be negative")
+	case hasMin && !hasMax && n < min:
+		errs.add(path, value, "must be at least %v", min)
+	case hasMax && !hasMin && n > max:
+		errs.add(path, value, "must be at most %v", max)
+	}
+}
+
+// enumStrings returns the string values of the enum of schema, or nil if
+// it has none
+func enumStrings(schema map[string]interface{}) []string {
+	switch enum := schema["enum"].(type) {
+	case []string:
+		return enum
+	case []interface{}:
+		var values []string
+		for _, value := range enum {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// numericValue returns value as a float64 if it is a number, including
+// numeric types such as Duration
+func numericValue(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RedactedValue replaces secret values when configuration is displayed
+const RedactedValue = "********"
+
+// secretCommandTimeout bounds how long a ${cmd:...} reference may run
+const secretCommandTimeout = 10 * time.Second
+
+// secretRef matches ${env:NAME}, ${file:/path} and ${cmd:command} references
+var secretRef = regexp.MustCompile(`\$\{(env|file|cmd):([^}]*)\}`)
+
+// resolveSecrets replaces secret references in every string value of the
+// merged tree. Values may embed references anywhere ("${env:USER}@db"), or
+// consist of a bare "file:/path". The original reference is recorded in
+// the value's origin so it can be redacted later.
+func resolveSecrets(tree map[string]interface{}, prefix string, origins Origins, errs *ValidationError) {
+	for key, value := range tree {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			resolveSecrets(v, path, origins, errs)
+
+		case string:
+			resolved, ok := resolveSecretValue(v, path, errs)
+			if ok {
+				tree[key] = resolved
+				markSecret(origins, path, v)
+			}
+
+		case []interface{}:
+			items := make([]interface{}, len(v))
+			found := false
+			for i, item := range v {
+				items[i] = item
+				if s, isString := item.(string); isString {
+					if resolved, ok := resolveSecretValue(s, fmt.Sprintf("%s[%d]", path, i), errs); ok {
+						items[i] = resolved
+						found = true
+					}
+				}
+			}
+			if found {
+				tree[key] = items
+				markSecret(origins, path, "")
+			}
+
+		case []string:
+			items := make([]string, len(v))
+			found := false
+			for i, item := range v {
+				items[i] = item
+				if resolved, ok := resolveSecretValue(item, fmt.Sprintf("%s[%d]", path, i), errs); ok {
+					items[i] = resolved
+					found = true
+				}
+			}
+			if found {
+				tree[key] = items
+				markSecret(origins, path, "")
+			}
+		}
+	}
+}
+
+// rejectLocalRefs adds an error for every ${cmd:...}, ${file:...} and bare
+// file: reference in the tree of a layer that is not trusted. source names
+// the layer in the messages.
+func rejectLocalRefs(tree map[string]interface{}, prefix, source string, errs *ValidationError) {
+	check := func(s, path string) {
+		kinds := make(map[string]bool)
+		if strings.HasPrefix(s, "file:") && !strings.Contains(s, "${") {
+			kinds["file"] = true
+		}
+		for _, match := range secretRef.FindAllStringSubmatch(s, -1) {
+			kinds[match[1]] = true
+		}
+		for _, kind := range []string{"cmd", "file"} {
+			if kinds[kind] {
+				errs.add(path, nil, "%s references are not allowed in remote config %s", kind, source)
+			}
+		}
+	}
+
+	for key, value := range tree {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			rejectLocalRefs(v, path, source, errs)
+		case string:
+			check(v, path)
+		case []interface{}:
+			for i, item := range v {
+				if s, ok := item.(string); ok {
+					check(s, fmt.Sprintf("%s[%d]", path, i))
+				}
+			}
+		case []string:
+			for i, item := range v {
+				check(item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+// isSecretRef reports whether s holds an unresolved secret reference, as
+// values checked by Parse do
+func isSecretRef(s string) bool {
+	return strings.HasPrefix(s, "file:") || secretRef.MatchString(s)
+}
+
+// markSecret records the reference a value was resolved from
+func markSecret(origins Origins, path, ref string) {
+	origin := origins[path]
+	origin.Secret = true
+	origin.Ref = ref
+	origins[path] = origin
+}
+
+// resolveSecretValue resolves the references in s. It reports whether s
+// contained any reference; failures are added to errs.
+func resolveSecretValue(s, path string, errs *ValidationError) (string, bool) {
+	if strings.HasPrefix(s, "file:") && !strings.Contains(s, "${") {
+		value, err := resolveRef("file", strings.TrimPrefix(s, "file:"))
+		if err != nil {
+			errs.add(path, nil, "cannot resolve %q: %v", s, err)
+		}
+		return value, true
+	}
+
+	if !secretRef.MatchString(s) {
+		return s, false
+	}
+
+	resolved := secretRef.ReplaceAllStringFunc(s, func(ref string) string {
+		match := secretRef.FindStringSubmatch(ref)
+		value, err := resolveRef(match[1], match[2])
+		if err != nil {
+			errs.add(path, nil, "cannot resolve %q: %v", ref, err)
+		}
+		return value
+	})
+	return resolved, true
+}
+
+// resolveRef returns the value of a single reference
+func resolveRef(kind, arg string) (string, error) {
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", arg)
+		}
+		return value, nil
+
+	case "file":
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case "cmd":
+		ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+		defer cancel()
+
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", arg)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", arg)
+		}
+
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("command failed: %w", err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+
+	return "", fmt.Errorf("unknown reference type %q", kind)
+}
+
+// Redacted returns a copy of c with secrets replaced by RedactedValue.
+// Secrets are fields tagged secret:"true" and any value that origins marks
+// as resolved from a secret reference.
+func (c *Config) Redacted(origins Origins) *Config {
+	redacted := c.Clone()
+	secrets := make(map[string]bool)
+	secretFields(reflect.TypeOf(*c), "", secrets)
+
+	walkFields(reflect.ValueOf(redacted).Elem(), "", func(path string, field reflect.Value) {
+		if !secrets[path] && !origins[path].Secret {
+			return
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			if field.String() != "" {
+				field.SetString(RedactedValue)
+			}
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.String {
+				for i := 0; i < field.Len(); i++ {
+					field.Index(i).SetString(RedactedValue)
+				}
+			}
+		}
+	})
+
+	return redacted
+}
+
+// secretFields collects the paths of struct fields tagged secret:"true"
+func secretFields(t reflect.Type, prefix string, paths map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := yamlName(sf)
+		if name == "" {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			secretFields(sf.Type, path, paths)
+			continue
+		}
+		if sf.Tag.Get("secret") == "true" {
+			paths[path] = true
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Document is one configuration document read from a source, such as a
+// config file, a conf.d fragment or the body of an HTTP response
+type Document struct {
+	Name   string // Identifies the document in origins and errors
+	Format Format
+	Data   []byte
+	Remote bool // Fetched over the network; see Layer.Trusted
+}
+
+// ConfigSource provides configuration documents to a Loader. Documents from
+// all sources go through the same decoding, validation and precedence
+// rules, whatever their origin, except that remote documents may not use
+// ${cmd:...} and ${file:...} references.
+type ConfigSource interface {
+	// Name identifies the source in errors
+	Name() string
+
+	// Read returns the current documents of the source in increasing
+	// order of precedence
+	Read(ctx context.Context) ([]Document, error)
+
+	// Watch starts watching the source and calls changed whenever its
+	// documents may have changed. Spurious calls are harmless: the loader
+	// only reloads when the content differs. The returned function stops
+	// watching.
+	Watch(changed func()) (stop func(), err error)
+}
+
+// FileSource reads a local config file plus, optionally, drop-in fragments
+// from a conf.d directory which are merged on top of it in lexical order.
+//
+// Rather than the files themselves, the source watches their parent
+// directories and the directories of any symlink targets. This keeps
+// watching working across editors that save by renaming a temporary file,
+// Kubernetes ConfigMap "..data" symlink swaps, and files being deleted and
+// recreated.
+type FileSource struct {
+	path    string
+	format  Format
+	confDir string
+}
+
+// NewFileSource creates a source for the config file at path
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// SetFormat sets the format of the config file. By default it is chosen
+// from the file extension. Fragments in the conf.d directory always use
+// their extension.
+func (s *FileSource) SetFormat(format Format) {
+	s.format = format
+}
+
+// SetConfDir sets a directory of *.yaml, *.json or *.toml fragments merged
+// on top of the config file
+func (s *FileSource) SetConfDir(dir string) {
+	s.confDir = dir
+}
+
+// Name implements ConfigSource
+func (s *FileSource) Name() string {
+	return s.path
+}
+
+// Read implements ConfigSource. It reads the config file followed by the
+// conf.d fragments.
+func (s *FileSource) Read(ctx context.Context) ([]Document, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	format := s.format
+	if format == "" {
+		format = FormatFor(s.path)
+	}
+	docs := []Document{{Name: s.path, Format: format, Data: data}}
+
+	fragments, err := s.fragments()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range fragments {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		docs = append(docs, Document{Name: path, Format: FormatFor(path), Data: data})
+	}
+
+	return docs, nil
+}
+
+// fragments returns the config fragments in the conf.d directory in the
+// order they are merged
+func (s *FileSource) fragments() ([]string, error) {
+	if s.confDir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(s.confDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isFragment(entry.Name()) {
+			continue
+		}
+		paths = append(paths, filepath.Join(s.confDir, entry.Name()))
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// isFragment reports whether a file name in conf.d is a config fragment.
+// Hidden files such as editor swap files are ignored.
+func isFragment(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	return isConfigExt(filepath.Ext(name))
+}
+
+// Watch implements ConfigSource. Changes to the config file, to conf.d
+// fragments being added, edited or removed, and to the symlinks leading to
+// them are reported. Other files in the watched directories, such as logs,
+// are ignored.
+func (s *FileSource) Watch(changed func()) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[string]bool)
+	if err := s.updateWatches(watcher, watched); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	names := s.watchNames()
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !s.relevant(event.Name, names) {
+					continue
+				}
+
+				// Symlink targets may have moved, e.g. after a ConfigMap swap
+				if err := s.updateWatches(watcher, watched); err != nil {
+					slog.Warn("Failed to update config file watches", "source", s.Name(), "error", err)
+				}
+				names = s.watchNames()
+				changed()
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("Config file watcher error", "source", s.Name(), "error", err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { watcher.Close() }) }, nil
+}
+
+// relevant reports whether an event for the file at path may change the
+// documents of the source: it is one of names, or a fragment in conf.d
+func (s *FileSource) relevant(path string, names map[string]bool) bool {
+	if names[path] {
+		return true
+	}
+	if s.confDir == "" || !isFragment(filepath.Base(path)) {
+		return false
+	}
+	dir := filepath.Dir(path)
+	for _, confDir := range s.confDirs() {
+		if dir == confDir {
+			return true
+		}
+	}
+	return false
+}
+
+// watchNames returns the absolute paths of the files whose events are
+// relevant: the config file and the existing fragments, where they resolve
+// to, and the first link on the way there, such as the "..data" link of a
+// Kubernetes ConfigMap
+func (s *FileSource) watchNames() map[string]bool {
+	names := make(map[string]bool)
+	var add func(path string)
+	add = func(path string) {
+		abs, err := filepath.Abs(path)
+		if err != nil || names[abs] {
+			return // Also stops at symlink loops
+		}
+		names[abs] = true
+		if target, err := filepath.EvalSymlinks(path); err == nil {
+			if abs, err := filepath.Abs(target); err == nil {
+				names[abs] = true
+			}
+		}
+		if link, err := os.Readlink(path); err == nil {
+			if filepath.IsAbs(link) {
+				names[filepath.Clean(link)] = true
+			} else if first := strings.Split(filepath.ToSlash(link), "/")[0]; first != ".." {
+				add(filepath.Join(filepath.Dir(path), first))
+			}
+		}
+	}
+
+	add(s.path)
+	fragments, _ := s.fragments()
+	for _, path := range fragments {
+		add(path)
+	}
+	return names
+}
+
+// confDirs returns the absolute conf.d directory and where it resolves to
+func (s *FileSource) confDirs() []string {
+	dirs := []string{s.confDir}
+	if target, err := filepath.EvalSymlinks(s.confDir); err == nil {
+		dirs = append(dirs, target)
+	}
+	for i, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dirs[i] = abs
+		}
+	}
+	return dirs
+}
+
+// watchDirs returns the directories that need to be watched: the parents
+// of the config file and conf.d directory, plus the directories their
+// symlinks currently point into
+func (s *FileSource) watchDirs() []string {
+	dirs := []string{filepath.Dir(s.path)}
+	if target, err := filepath.EvalSymlinks(s.path); err == nil {
+		dirs = append(dirs, filepath.Dir(target))
+	}
+
+	if s.confDir != "" {
+		dirs = append(dirs, s.confDir)
+		if target, err := filepath.EvalSymlinks(s.confDir); err == nil {
+			dirs = append(dirs, target)
+		}
+	}
+
+	for i, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dirs[i] = abs
+		}
+	}
+	return dirs
+}
+
+// updateWatches watches the current set of directories, dropping
+// directories that symlinks no longer point into
+func (s *FileSource) updateWatches(watcher *fsnotify.Watcher, watched map[string]bool) error {
+	wanted := make(map[string]bool)
+	for _, dir := range s.watchDirs() {
+		wanted[dir] = true
+	}
+
+	for dir := range watched {
+		if !wanted[dir] {
+			watcher.Remove(dir)
+			delete(watched, dir)
+		}
+	}
+
+	for dir := range wanted {
+		if watched[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch config directory %s: %w", dir, err)
+		}
+		watched[dir] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// configServer is an HTTP stand-in for a central config service
+type configServer struct {
+	mu          sync.Mutex
+	body        string
+	version     int
+	notModified int32
+}
+
+func (s *configServer) set(body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body = body
+	s.version++
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	etag := `"` + strconv.Itoa(s.version) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		atomic.AddInt32(&s.notModified, 1)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, s.body)
+}
+
+func TestHTTPSourcePollsWithETag(t *testing.T) {
+	srv := &configServer{}
+	srv.set(`{"server": {"port": 8701}}`)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	// A local file provides the base, the central endpoint overrides it
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "server:\n  host: local.example\n  port: 8700\n")
+
+	loader := NewSourceLoader(NewFileSource(path), NewHTTPSource(ts.URL+"/stroganoff", 20*time.Millisecond))
+	defer loader.Stop()
+
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cfg := GetInstance().Get()
+	if cfg.Server.Host != "local.example" || cfg.Server.Port != 8701 {
+		t.Fatalf("Server = %+v, want host from file and port from HTTP", cfg.Server)
+	}
+	if origin := GetInstance().Origins()["server.port"]; origin.Source != ts.URL+"/stroganoff" {
+		t.Fatalf("server.port origin = %v", origin)
+	}
+
+	var notified int32
+	unsubscribe := GetInstance().Subscribe(func(c Change) { atomic.AddInt32(&notified, 1) }, "server")
+	defer unsubscribe()
+
+	if err := loader.StartWatching(); err != nil {
+		t.Fatalf("StartWatching failed: %v", err)
+	}
+
+	// Unchanged documents are answered with 304 and cause no reload
+	waitFor(t, "conditional requests", func() bool { return atomic.LoadInt32(&srv.notModified) >= 3 })
+	if n := atomic.LoadInt32(&notified); n != 0 {
+		t.Fatalf("got %d notifications for an unchanged document", n)
+	}
+
+	srv.set(`{"server": {"port": 8702}}`)
+	waitFor(t, "remote change to be loaded", portIs(8702))
+
+	// Invalid documents are rejected like invalid files
+	srv.set(`{"server": {"port": 0}}`)
+	waitFor(t, "invalid document to be rejected", func() bool { return loader.LastError() != nil })
+	if port := GetInstance().GetServer().Port; port != 8702 {
+		t.Fatalf("Server.Port = %d, want last good value 8702", port)
+	}
+}
+
+func TestHTTPSourceReportsErrors(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	loader := NewSourceLoader(NewHTTPSource(ts.URL, time.Second))
+	defer loader.Stop()
+
+	if err := loader.Load(); err == nil {
+		t.Fatal("Load should fail when the endpoint returns 404")
+	}
+}
+
+func TestHTTPSourceRejectsLocalReferences(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "pwned")
+	t.Setenv("TEST_REMOTE_DB_USER", "app")
+
+	srv := &configServer{}
+	srv.set(`{"database": {"user": "${env:TEST_REMOTE_DB_USER}"}}`)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	loader := NewSourceLoader(NewHTTPSource(ts.URL, time.Second))
+	defer loader.Stop()
+
+	// Environment references are allowed
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if user := GetInstance().Get().Database.User; user != "app" {
+		t.Fatalf("Database.User = %q, want app", user)
+	}
+
+	for _, body := range []string{
+		`{"database": {"password": "${cmd:touch ` + marker + `}"}}`,
+		`{"database": {"password": "${file:` + marker + `}"}}`,
+		`{"database": {"password": "file:` + marker + `"}}`,
+	} {
+		srv.set(body)
+		err := loader.Load()
+		verr, ok := err.(*ValidationError)
+		if !ok || len(verr.Errors) != 1 || verr.Errors[0].Path != "database.password" {
+			t.Errorf("Load(%s) = %v, want a database.password field error", body, err)
+		}
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("command from the remote document ran: %v", err)
+	}
+}
+
+// memoryKV is a KVStore backed by a map
+type memoryKV struct {
+	mu       sync.Mutex
+	values   map[string][]byte
+	revision int
+}
+
+func (kv *memoryKV) put(key, value string) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.values[key] = []byte(value)
+	kv.revision++
+}
+
+func (kv *memoryKV) Get(ctx context.Context, key string) ([]byte, string, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	value, ok := kv.values[key]
+	if !ok {
+		return nil, "", fmt.Errorf("key %s not found", key)
+	}
+	return value, strconv.Itoa(kv.revision), nil
+}
+
+func TestKVSourceWatchesVersion(t *testing.T) {
+	kv := &memoryKV{values: make(map[string][]byte)}
+	kv.put("stroganoff/config.toml", "[server]\nport = 8801\n")
+
+	loader := NewSourceLoader(NewKVSource(kv, "stroganoff/config.toml", 20*time.Millisecond))
+	defer loader.Stop()
+
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if port := GetInstance().GetServer().Port; port != 8801 {
+		t.Fatalf("Server.Port = %d, want 8801", port)
+	}
+
+	if err := loader.StartWatching(); err != nil {
+		t.Fatalf("StartWatching failed: %v", err)
+	}
+
+	kv.put("stroganoff/config.toml", "[server]\nport = 8802\n")
+	waitFor(t, "KV change to be loaded", portIs(8802))
+}
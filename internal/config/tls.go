@@ -0,0 +1,50 @@
+package config
+
+import (
+	"crypto/tls"
+	"strconv"
+	"strings"
+)
+
+// TLSVersionName is a TLS version as configured, e.g. "1.2". Unquoted
+// YAML numbers are accepted too: 1.2 reads as "1.2" and 1 as "1.0".
+type TLSVersionName string
+
+// UnmarshalText implements encoding.TextUnmarshaler. Unknown versions are
+// kept and reported by validation.
+func (v *TLSVersionName) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+	}
+	*v = TLSVersionName(s)
+	return nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSVersion returns the crypto/tls constant for a tls_min_version value
+func TLSVersion(name TLSVersionName) (uint16, bool) {
+	version, ok := tlsVersions[string(name)]
+	return version, ok
+}
+
+// CipherSuite returns the ID of the cipher suite with the given standard
+// name, e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". Suites that
+// crypto/tls considers insecure are not accepted.
+func CipherSuite(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
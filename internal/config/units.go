@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration configured as a Go duration string such as
+// "250ms" or "1m30s". Bare numbers are read as seconds, so configurations
+// written when durations were plain integers keep working.
+type Duration time.Duration
+
+// Duration returns d as a time.Duration
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String returns d in Go duration syntax
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.Abs(seconds) > math.MaxInt64/float64(time.Second) {
+			return fmt.Errorf("duration %q out of range", s)
+		}
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q (want e.g. \"30s\", \"1m30s\" or a number of seconds)", s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ByteSize is a number of bytes configured either as a plain number or
+// with a unit such as "512KiB", "10MiB" or "1GB". Binary units (KiB, MiB,
+// GiB, TiB) are powers of 1024, decimal units (KB, MB, GB, TB) powers of
+// 1000.
+type ByteSize int64
+
+// Byte size units
+const (
+	Byte ByteSize = 1
+	KiB           = 1024 * Byte
+	MiB           = 1024 * KiB
+	GiB           = 1024 * MiB
+	TiB           = 1024 * GiB
+)
+
+var byteSizeUnits = map[string]ByteSize{
+	"":    Byte,
+	"b":   Byte,
+	"k":   KiB,
+	"kb":  1000,
+	"kib": KiB,
+	"m":   MiB,
+	"mb":  1000 * 1000,
+	"mib": MiB,
+	"g":   GiB,
+	"gb":  1000 * 1000 * 1000,
+	"gib": GiB,
+	"t":   TiB,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"tib": TiB,
+}
+
+// String returns s using the largest binary unit that represents it
+// exactly, e.g. "10MiB"
+func (s ByteSize) String() string {
+	for _, unit := range []struct {
+		name string
+		size ByteSize
+	}{{"TiB", TiB}, {"GiB", GiB}, {"MiB", MiB}, {"KiB", KiB}} {
+		if s != 0 && s%unit.size == 0 {
+			return fmt.Sprintf("%d%s", s/unit.size, unit.name)
+		}
+	}
+	return fmt.Sprintf("%dB", int64(s))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s ByteSize) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *ByteSize) UnmarshalText(text []byte) error {
+	str := strings.TrimSpace(string(text))
+
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	})
+	if i < 0 {
+		i = len(str)
+	}
+	number, unit := str[:i], strings.ToLower(strings.TrimSpace(str[i:]))
+
+	multiplier, ok := byteSizeUnits[unit]
+	if !ok {
+		return fmt.Errorf("invalid byte size %q: unknown unit %q", str, str[i:])
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return fmt.Errorf("invalid byte size %q (want e.g. \"512KiB\", \"10MiB\" or a number of bytes)", str)
+	}
+	if math.Abs(n*float64(multiplier)) > math.MaxInt64 {
+		return fmt.Errorf("byte size %q out of range", str)
+	}
+
+	*s = ByteSize(n * float64(multiplier))
+	return nil
+}
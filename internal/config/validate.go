@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FieldError describes a problem with a single configuration field
+type FieldError struct {
+	Path    string      // Dotted yaml path of the field, e.g. "server.port"
+	Value   interface{} // Offending value, nil for unknown keys
+	Message string
+}
+
+// Error implements the error interface
+func (e *FieldError) Error() string {
+	if e.Value == nil {
+		return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s (got %v)", e.Path, e.Message, e.Value)
+}
+
+// ValidationError is returned when a configuration is rejected. It holds
+// every problem found rather than just the first one.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, "invalid configuration:")
+	for _, fe := range e.Errors {
+		lines = append(lines, "  "+fe.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// add records a field error
+func (e *ValidationError) add(path string, value interface{}, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, &FieldError{
+		Path:    path,
+		Value:   value,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// err returns e if any errors were recorded, nil otherwise
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ThemeExists reports whether a theme name is known, given the configured
+// themes directory. It can be replaced by packages that discover themes at
+// runtime.
+var ThemeExists = func(name, themesDir string) bool {
+	return name == "default" || name == "dark"
+}
+
+// minAdminTokenLength is the shortest accepted api.admin_token
+const minAdminTokenLength = 32
+
+// Validate checks the configuration for values the application cannot run
+// with. Ranges and allowed values come from the configuration schema (see
+// Schema); rules spanning several fields are checked here. All problems are
+// reported in a single *ValidationError.
+func (c *Config) Validate() error {
+	return c.validate(true)
+}
+
+// validate implements Validate. Without onHost the configuration is
+// checked for use on another machine, so checks against the local file
+// system are skipped: the themes directory is not looked at, and only
+// built-in themes can be checked.
+func (c *Config) validate(onHost bool) error {
+	errs := &ValidationError{}
+	validateSchema(Schema(), c.Tree(), "", errs)
+
+	// Server
+	if onHost && c.Server.ThemesDir != "" {
+		if info, err := os.Stat(c.Server.ThemesDir); err != nil || !info.IsDir() {
+			errs.add("server.themes_dir", c.Server.ThemesDir, "not a directory")
+		}
+	}
+	if (onHost || c.Server.ThemesDir == "") && c.Server.Theme != "" && !ThemeExists(c.Server.Theme, c.Server.ThemesDir) {
+		errs.add("server.theme", c.Server.Theme, "unknown theme")
+	}
+	if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		errs.add("server.tls_key", nil, "tls_cert and tls_key must be set together")
+	}
+	for i, name := range c.Server.TLSCipherSuites {
+		if _, ok := CipherSuite(name); !ok {
+			errs.add(fmt.Sprintf("server.tls_cipher_suites[%d]", i), name, "unknown or insecure cipher suite")
+		}
+	}
+	if c.Server.HTTPRedirectPort != 0 {
+		if c.Server.TLSCert == "" {
+			errs.add("server.http_redirect_port", c.Server.HTTPRedirectPort, "requires tls_cert and tls_key")
+		} else if c.Server.HTTPRedirectPort == c.Server.Port {
+			errs.add("server.http_redirect_port", c.Server.HTTPRedirectPort, "must differ from port")
+		}
+	}
+
+	// API
+	if c.API.RateLimit > 0 && c.API.RateLimitWindow <= 0 {
+		errs.add("api.rate_limit_window", c.API.RateLimitWindow, "must be positive when rate_limit is set")
+	}
+	if c.API.AdminToken != "" && len(c.API.AdminToken) < minAdminTokenLength && !isSecretRef(c.API.AdminToken) {
+		errs.add("api.admin_token", nil, "must be at least %d characters", minAdminTokenLength)
+	}
+	for i, origin := range c.API.AllowedOrigins {
+		if strings.TrimSpace(origin) == "" {
+			errs.add(fmt.Sprintf("api.allowed_origins[%d]", i), nil, "must not be empty")
+		}
+	}
+
+	return errs.err()
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
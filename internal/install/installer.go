@@ -1,5 +1,12 @@
 package install
 
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
 // ServiceInstaller defines the interface for service installation
 type ServiceInstaller interface {
 	Install() error
@@ -7,3 +14,18 @@ type ServiceInstaller interface {
 	Start() error
 	Stop() error
 }
+
+// runCommand runs a service manager command. If it fails, the error
+// includes the command's output.
+func runCommand(name string, args ...string) error {
+	slog.Debug("Running service manager command", "command", name, "args", args)
+
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, out)
+		}
+		return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
@@ -2,8 +2,8 @@ package install
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"text/template"
 )
@@ -74,8 +74,10 @@ func (li *LaunchdInstaller) Install() error {
 		return err
 	}
 
+	slog.Info("Wrote launchd property list", "path", plistFile)
+
 	// Load the service
-	return exec.Command("launchctl", "load", plistFile).Run()
+	return runCommand("launchctl", "load", plistFile)
 }
 
 // Uninstall removes the launchd service
@@ -87,7 +89,7 @@ func (li *LaunchdInstaller) Uninstall() error {
 
 	plistFile := filepath.Join(homeDir, "Library/LaunchAgents", fmt.Sprintf("com.%s.plist", li.serviceName))
 
-	if err := exec.Command("launchctl", "unload", plistFile).Run(); err != nil {
+	if err := runCommand("launchctl", "unload", plistFile); err != nil {
 		return err
 	}
 
@@ -96,10 +98,10 @@ func (li *LaunchdInstaller) Uninstall() error {
 
 // Start starts the launchd service
 func (li *LaunchdInstaller) Start() error {
-	return exec.Command("launchctl", "start", fmt.Sprintf("com.%s", li.serviceName)).Run()
+	return runCommand("launchctl", "start", fmt.Sprintf("com.%s", li.serviceName))
 }
 
 // Stop stops the launchd service
 func (li *LaunchdInstaller) Stop() error {
-	return exec.Command("launchctl", "stop", fmt.Sprintf("com.%s", li.serviceName)).Run()
+	return runCommand("launchctl", "stop", fmt.Sprintf("com.%s", li.serviceName))
 }
@@ -2,8 +2,8 @@ package install
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
-	"os/exec"
 	"text/template"
 )
 
@@ -38,6 +38,7 @@ After=network.target
 Type=simple
 User={{.User}}
 ExecStart={{.BinaryPath}}
+ExecReload=/bin/kill -USR1 $MAINPID
 Restart=on-failure
 RestartSec=10
 
@@ -64,13 +65,15 @@ WantedBy=multi-user.target
 		return err
 	}
 
+	slog.Info("Wrote systemd unit", "path", serviceFile)
+
 	// Reload systemd and enable service
-	if err := exec.Command("systemctl", "daemon-reload").Run(); err != nil {
+	if err := runCommand("systemctl", "daemon-reload"); err != nil {
 		os.Remove(serviceFile)
 		return err
 	}
 
-	if err := exec.Command("systemctl", "enable", si.serviceName).Run(); err != nil {
+	if err := runCommand("systemctl", "enable", si.serviceName); err != nil {
 		os.Remove(serviceFile)
 		return err
 	}
@@ -82,23 +85,24 @@ WantedBy=multi-user.target
 func (si *SystemdInstaller) Uninstall() error {
 	serviceFile := fmt.Sprintf("/etc/systemd/system/%s.service", si.serviceName)
 
-	if err := exec.Command("systemctl", "disable", si.serviceName).Run(); err != nil {
+	if err := runCommand("systemctl", "disable", si.serviceName); err != nil {
 		return err
 	}
 
 	if err := os.Remove(serviceFile); err != nil {
 		return err
 	}
+	slog.Info("Removed systemd unit", "path", serviceFile)
 
-	return exec.Command("systemctl", "daemon-reload").Run()
+	return runCommand("systemctl", "daemon-reload")
 }
 
 // Start starts the systemd service
 func (si *SystemdInstaller) Start() error {
-	return exec.Command("systemctl", "start", si.serviceName).Run()
+	return runCommand("systemctl", "start", si.serviceName)
 }
 
 // Stop stops the systemd service
 func (si *SystemdInstaller) Stop() error {
-	return exec.Command("systemctl", "stop", si.serviceName).Run()
+	return runCommand("systemctl", "stop", si.serviceName)
 }
@@ -1,9 +1,5 @@
 package install
 
-import (
-	"os/exec"
-)
-
 // WindowsServiceInstaller handles Windows Service installation
 type WindowsServiceInstaller struct {
 	serviceName string
@@ -22,20 +18,20 @@ func NewWindowsServiceInstaller(serviceName, binaryPath string) *WindowsServiceI
 func (wsi *WindowsServiceInstaller) Install() error {
 	// Use nssm (Non-Sucking Service Manager) or sc command
 	// This example uses sc.exe (built-in on Windows)
-	return exec.Command("sc", "create", wsi.serviceName, "binPath=", wsi.binaryPath).Run()
+	return runCommand("sc", "create", wsi.serviceName, "binPath=", wsi.binaryPath)
 }
 
 // Uninstall removes the Windows service
 func (wsi *WindowsServiceInstaller) Uninstall() error {
-	return exec.Command("sc", "delete", wsi.serviceName).Run()
+	return runCommand("sc", "delete", wsi.serviceName)
 }
 
 // Start starts the Windows service
 func (wsi *WindowsServiceInstaller) Start() error {
-	return exec.Command("sc", "start", wsi.serviceName).Run()
+	return runCommand("sc", "start", wsi.serviceName)
 }
 
 // Stop stops the Windows service
 func (wsi *WindowsServiceInstaller) Stop() error {
-	return exec.Command("sc", "stop", wsi.serviceName).Run()
+	return runCommand("sc", "stop", wsi.serviceName)
 }
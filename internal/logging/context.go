@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// contextKey is the type of the context key under which WithAttrs stores
+// attributes
+type contextKey struct{}
+
+// WithAttrs returns a copy of ctx carrying attrs in addition to those ctx
+// already carries. Records logged with the context, e.g. with
+// slog.InfoContext, include them.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, contextKey{}, combined)
+}
+
+// contextHandler adds the attributes stored with WithAttrs to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler wraps h so that records logged with a context include
+// the attributes added to it with WithAttrs. Setup wraps every handler it
+// builds.
+func NewContextHandler(h slog.Handler) slog.Handler {
+	return contextHandler{h}
+}
+
+// Handle implements slog.Handler
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
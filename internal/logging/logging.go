@@ -0,0 +1,143 @@
+// Package logging configures the process-wide slog logger from the logging
+// section of the configuration. Other packages log through the log/slog
+// package-level functions and need not import this package.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/stroganoff/internal/config"
+)
+
+var (
+	// level is shared by every handler built by Setup, so that the level
+	// can change without replacing the logger
+	level = new(slog.LevelVar)
+
+	mu      sync.Mutex
+	current *config.LoggingConfig
+	output  io.Closer
+)
+
+// Setup makes a logger configured by cfg the slog default. When only the
+// level changed since the last call the existing logger is kept and its
+// level is adjusted.
+func Setup(cfg config.LoggingConfig) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lvl, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+
+	if current != nil && sameOutput(*current, cfg) {
+		level.Set(lvl)
+		current = &cfg
+		return nil
+	}
+
+	w, closer, err := openOutput(cfg)
+	if err != nil {
+		return err
+	}
+	handler, err := newHandler(cfg.Format, w)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}
+
+	level.Set(lvl)
+	slog.SetDefault(slog.New(handler))
+
+	if output != nil {
+		output.Close()
+	}
+	output = closer
+	current = &cfg
+	return nil
+}
+
+// Watch applies changes to the logging section of the configuration as
+// they are loaded. The returned function stops watching.
+func Watch() (stop func()) {
+	return config.GetInstance().Subscribe(func(change config.Change) {
+		if err := Setup(change.New.Logging); err != nil {
+			slog.Error("Failed to apply logging configuration, keeping the previous one", "error", err)
+			return
+		}
+		slog.Info("Applied logging configuration", "level", change.New.Logging.Level, "format", change.New.Logging.Format, "output", change.New.Logging.OutputPath)
+	}, "logging")
+}
+
+// Close closes the log file opened by Setup, if any. The next call to
+// Setup builds a new logger.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = nil
+	if output == nil {
+		return nil
+	}
+	err := output.Close()
+	output = nil
+	return err
+}
+
+// ParseLevel parses a level name as used in logging.level. An empty name
+// means info.
+func ParseLevel(name string) (slog.Level, error) {
+	var lvl slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := lvl.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", name)
+	}
+	return lvl, nil
+}
+
+// newHandler returns a handler writing to w in the given format
+func newHandler(format string, w io.Writer) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(format) {
+	case "", "json":
+		return NewContextHandler(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return NewContextHandler(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q (want json or text)", format)
+}
+
+// sameOutput reports whether a and b only differ in level, so that the
+// logger built for a can be kept for b
+func sameOutput(a, b config.LoggingConfig) bool {
+	a.Level, b.Level = "", ""
+	return a == b
+}
+
+// openOutput opens the log destination. stdout and stderr are not closed;
+// files are appended to, rotated as configured and returned as closer.
+func openOutput(cfg config.LoggingConfig) (io.Writer, io.Closer, error) {
+	switch cfg.OutputPath {
+	case "", "stdout":
+		return os.Stdout, nil, nil
+	case "stderr":
+		return os.Stderr, nil, nil
+	}
+
+	file, err := openRotatingFile(cfg.OutputPath, int64(cfg.MaxSize), cfg.RotateInterval.Duration(), cfg.Compress, cfg.MaxAge.Duration(), cfg.MaxBackups)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file, nil
+}
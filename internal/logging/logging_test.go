@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/stroganoff/internal/config"
+)
+
+func TestSetupChangesLevelAtRuntime(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	defer Close()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg := config.LoggingConfig{Level: "info", Format: "text", OutputPath: path}
+	if err := Setup(cfg); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	slog.Debug("hidden")
+	slog.Info("shown", "key", "value")
+
+	cfg.Level = "debug"
+	if err := Setup(cfg); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	slog.Debug("now shown")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	if strings.Contains(log, "hidden") {
+		t.Fatalf("debug message logged at info level:\n%s", log)
+	}
+	if !strings.Contains(log, "msg=shown key=value") || !strings.Contains(log, `msg="now shown"`) {
+		t.Fatalf("expected messages missing:\n%s", log)
+	}
+}
+
+func TestSetupSwitchesFormat(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	defer Close()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := Setup(config.LoggingConfig{Format: "text", OutputPath: path}); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := Setup(config.LoggingConfig{Format: "json", OutputPath: path}); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	slog.Info("structured")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"structured"`) {
+		t.Fatalf("expected a JSON record, got:\n%s", data)
+	}
+}
+
+func TestSetupRejectsInvalidSettings(t *testing.T) {
+	for _, cfg := range []config.LoggingConfig{
+		{Level: "verbose"},
+		{Format: "xml"},
+		{OutputPath: filepath.Join(t.TempDir(), "missing", "app.log")},
+	} {
+		if err := Setup(cfg); err == nil {
+			t.Fatalf("Setup(%+v) should fail", cfg)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp in the names of rotated files, e.g.
+// app-20240102T150405.000.log for app.log
+const backupTimeFormat = "20060102T150405.000"
+
+// rotateRetry is how long a file that failed to rotate for its size keeps
+// growing before rotation is attempted again
+const rotateRetry = time.Minute
+
+// rotatingFile is a log file that is rotated when it grows beyond maxSize
+// or at every multiple of interval. Rotated files are optionally gzipped
+// and removed when they are older than maxAge or more than maxBackups
+// exist. Zero values disable the respective limit.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	compress   bool
+	maxAge     time.Duration
+	maxBackups int
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+
+	// stderr receives errors that cannot be logged to the file itself;
+	// replaced in tests
+	stderr io.Writer
+
+	mu       sync.Mutex
+	file     *os.File
+	closed   bool
+	size     int64
+	rotateAt time.Time
+	retryAt  time.Time // Earliest size-based rotation after a failed one
+	failing  bool      // A rotation failure was reported and none succeeded since
+
+	// mill serialises compression and cleanup, which run in the
+	// background so that writers are not held up
+	mill sync.Mutex
+	wg   sync.WaitGroup
+}
+
+// openRotatingFile opens path for appending
+func openRotatingFile(path string, maxSize int64, interval time.Duration, compress bool, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		compress:   compress,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		now:        time.Now,
+		stderr:     os.Stderr,
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write implements io.Writer. A write that would take the file beyond
+// maxSize rotates it first.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		// A previous rotation could not open the file
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	now := f.now()
+	due := !f.rotateAt.IsZero() && !now.Before(f.rotateAt)
+	full := f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize && !now.Before(f.retryAt)
+	if due || full {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Reopen closes and reopens the file, e.g. after an external tool such as
+// logrotate moved it away
+func (f *rotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	return f.open()
+}
+
+// Close closes the file and waits for background compression and cleanup
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.closed = true
+	f.mu.Unlock()
+
+	f.wg.Wait()
+	return err
+}
+
+// open opens the file and schedules the next time-based rotation. It must
+// be called with f.mu held.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.interval > 0 {
+		f.rotateAt = f.now().Truncate(f.interval).Add(f.interval)
+	}
+	return nil
+}
+
+// rotate moves the current file to a timestamped backup and opens a new
+// one. If that fails, e.g. for lack of permissions or disk space, logging
+// continues to the current file and the failure is reported to stderr
+// once; a size-based rotation is retried after rotateRetry. It must be
+// called with f.mu held.
+func (f *rotatingFile) rotate() error {
+	now := f.now()
+	backup := f.backupName(now)
+
+	err := f.file.Close()
+	f.file = nil
+	if err == nil {
+		err = os.Rename(f.path, backup)
+		if err == nil {
+			if err = f.open(); err != nil {
+				// Move the file back so that it can be reopened below
+				os.Rename(backup, f.path)
+			}
+		}
+	}
+	if err != nil {
+		f.retryAt = now.Add(rotateRetry)
+		if !f.failing {
+			f.failing = true
+			fmt.Fprintf(f.stderr, "Failed to rotate log file %s, continuing to write to it: %v\n", f.path, err)
+		}
+		if f.file == nil {
+			return f.open()
+		}
+		return nil
+	}
+	f.failing = false
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.mill.Lock()
+		defer f.mill.Unlock()
+
+		if f.compress {
+			if err := compressFile(backup); err != nil {
+				// The log itself cannot be used while its lock is held
+				fmt.Fprintf(f.stderr, "Failed to compress rotated log file %s: %v\n", backup, err)
+			}
+		}
+		f.removeExpired(now)
+	}()
+	return nil
+}
+
+// backupName returns the name of a file rotated at t
+func (f *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext)
+	return prefix + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// backup is a rotated log file
+type backup struct {
+	path    string
+	rotated time.Time
+}
+
+// backups returns the rotated files of f, newest first
+func (f *rotatingFile) backups() ([]backup, error) {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp = strings.TrimSuffix(stamp, ".gz")
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		rotated, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), rotated: rotated})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].rotated.After(backups[j].rotated)
+	})
+	return backups, nil
+}
+
+// removeExpired applies the retention policy to the rotated files as of
+// now
+func (f *rotatingFile) removeExpired(now time.Time) {
+	if f.maxAge <= 0 && f.maxBackups <= 0 {
+		return
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		fmt.Fprintf(f.stderr, "Failed to list rotated log files: %v\n", err)
+		return
+	}
+
+	cutoff := now.Add(-f.maxAge)
+	for i, b := range backups {
+		if (f.maxBackups > 0 && i >= f.maxBackups) || (f.maxAge > 0 && b.rotated.Before(cutoff)) {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(f.stderr, "Failed to remove rotated log file %s: %v\n", b.path, err)
+			}
+		}
+	}
+}
+
+// compressFile replaces path with a gzipped copy named path.gz
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// reopener is implemented by outputs that can be reopened
+type reopener interface {
+	Reopen() error
+}
+
+// Reopen reopens the log file, if logging to one. It is meant to be called
+// after an external tool such as logrotate moved the file away.
+func Reopen() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	r, ok := output.(reopener)
+	if !ok {
+		return nil
+	}
+	if err := r.Reopen(); err != nil {
+		return err
+	}
+	slog.Info("Reopened log file", "path", current.OutputPath)
+	return nil
+}
@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a fake time source advanced by tests
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func openTestFile(t *testing.T, maxSize int64, interval time.Duration, compress bool, maxAge time.Duration, maxBackups int) (*rotatingFile, *clock) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := openRotatingFile(path, maxSize, interval, compress, maxAge, maxBackups)
+	if err != nil {
+		t.Fatalf("openRotatingFile failed: %v", err)
+	}
+	c := &clock{t: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)}
+	f.now = c.now
+	f.rotateAt = time.Time{}
+	if interval > 0 {
+		f.rotateAt = c.t.Truncate(interval).Add(interval)
+	}
+	return f, c
+}
+
+func write(t *testing.T, f *rotatingFile, s string) {
+	t.Helper()
+	if _, err := io.WriteString(f, s); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+}
+
+func backupNames(t *testing.T, f *rotatingFile) []string {
+	t.Helper()
+	f.wg.Wait()
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, b := range backups {
+		names = append(names, filepath.Base(b.path))
+	}
+	return names
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatesBySizeAndCompresses(t *testing.T) {
+	f, c := openTestFile(t, 10, 0, true, 0, 0)
+	defer f.Close()
+
+	write(t, f, "first\n")
+	c.t = c.t.Add(time.Second)
+	write(t, f, "second\n")
+
+	names := backupNames(t, f)
+	if len(names) != 1 || names[0] != "app-20240102T150406.000.log.gz" {
+		t.Fatalf("backups = %v, want one compressed backup", names)
+	}
+
+	gz, err := os.Open(filepath.Join(filepath.Dir(f.path), names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != "first\n" {
+		t.Fatalf("backup contains %q, want the first line", data)
+	}
+	if got := readFile(t, f.path); got != "second\n" {
+		t.Fatalf("log file contains %q, want the second line", got)
+	}
+}
+
+func TestRotatesByInterval(t *testing.T) {
+	f, c := openTestFile(t, 0, time.Hour, false, 0, 0)
+	defer f.Close()
+
+	write(t, f, "before\n")
+	c.t = c.t.Add(30 * time.Minute)
+	write(t, f, "same hour\n")
+	if names := backupNames(t, f); len(names) != 0 {
+		t.Fatalf("rotated before the interval ended: %v", names)
+	}
+
+	c.t = c.t.Add(time.Hour)
+	write(t, f, "after\n")
+	names := backupNames(t, f)
+	if len(names) != 1 || !strings.HasSuffix(names[0], ".log") {
+		t.Fatalf("backups = %v, want one uncompressed backup", names)
+	}
+	if got := readFile(t, f.path); got != "after\n" {
+		t.Fatalf("log file contains %q", got)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	f, c := openTestFile(t, 1, 0, false, 90*time.Minute, 3)
+	defer f.Close()
+
+	// Every write after the first rotates; rotations are an hour apart
+	for i := 0; i < 6; i++ {
+		write(t, f, "line\n")
+		c.t = c.t.Add(time.Hour)
+	}
+
+	// Five backups were made; only the newest within max_age remain
+	names := backupNames(t, f)
+	if len(names) != 2 {
+		t.Fatalf("backups = %v, want the 2 newer than 90 minutes", names)
+	}
+
+	f.maxAge = 0
+	for i := 0; i < 4; i++ {
+		write(t, f, "line\n")
+		c.t = c.t.Add(time.Hour)
+	}
+	if names := backupNames(t, f); len(names) != 3 {
+		t.Fatalf("backups = %v, want max_backups 3", names)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	f, _ := openTestFile(t, 0, 0, false, 0, 0)
+	defer f.Close()
+
+	write(t, f, "old\n")
+	moved := f.path + ".1"
+	if err := os.Rename(f.path, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	write(t, f, "new\n")
+
+	if got := readFile(t, moved); got != "old\n" {
+		t.Fatalf("moved file contains %q", got)
+	}
+	if got := readFile(t, f.path); got != "new\n" {
+		t.Fatalf("reopened file contains %q", got)
+	}
+}
+
+func TestRotationFailureKeepsLogging(t *testing.T) {
+	f, c := openTestFile(t, 10, 0, false, 0, 0)
+	defer f.Close()
+	var stderr strings.Builder
+	f.stderr = &stderr
+
+	// Directories in the way of the next two backups make renaming fail
+	for _, at := range []time.Time{c.t, c.t.Add(rotateRetry)} {
+		if err := os.MkdirAll(filepath.Join(f.backupName(at), "blocked"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(t, f, "first\n")
+	write(t, f, "second\n")
+	write(t, f, "third\n") // Not retried before rotateRetry
+	c.t = c.t.Add(rotateRetry)
+	write(t, f, "fourth\n")
+	if got := readFile(t, f.path); got != "first\nsecond\nthird\nfourth\n" {
+		t.Fatalf("log file contains %q, want every line", got)
+	}
+	if n := strings.Count(stderr.String(), "Failed to rotate"); n != 1 {
+		t.Fatalf("rotation failure reported %d times, want once:\n%s", n, stderr.String())
+	}
+
+	c.t = c.t.Add(rotateRetry)
+	write(t, f, "fifth\n")
+	if got := readFile(t, f.path); got != "fifth\n" {
+		t.Fatalf("log file contains %q after a successful rotation", got)
+	}
+	if names := backupNames(t, f); len(names) != 1 {
+		t.Fatalf("backups = %v, want one", names)
+	}
+}
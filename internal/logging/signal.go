@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// ReopenOnSignal reopens the log file whenever the process receives
+// SIGUSR1, so that external tools such as logrotate can move it away. It
+// does nothing on platforms without SIGUSR1. The returned function stops
+// listening for the signal.
+func ReopenOnSignal() (stop func()) {
+	if len(reopenSignals) == 0 {
+		return func() {}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, reopenSignals...)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := Reopen(); err != nil {
+					slog.Error("Failed to reopen log file", "error", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
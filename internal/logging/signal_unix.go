@@ -0,0 +1,11 @@
+//go:build !windows
+
+package logging
+
+import (
+	"os"
+	"syscall"
+)
+
+// reopenSignals make the log file be reopened, see ReopenOnSignal
+var reopenSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,276 @@
+package themes
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxExtractedSize bounds the total size of the files extracted from a
+// theme archive
+const maxExtractedSize = 256 << 20
+
+// Install validates the theme in a .zip, .tar.gz or .tgz archive and
+// installs it into dir, creating dir if needed. The manifest must be at the
+// root of the archive or of its single top-level directory. An installed
+// theme of the same name is only replaced if replace is set. Built-in
+// theme names cannot be used; a theme extends a built-in one by naming it
+// as parent.
+//
+// The theme is moved into place in one step, so a running server sees
+// either the old or the new theme.
+func Install(archive, dir string, replace bool) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create themes directory: %w", err)
+	}
+
+	// Extract next to the destination, so that it can be renamed into place
+	tmp, err := os.MkdirTemp(dir, ".install-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// Keep the extracted files apart from a replaced theme moved aside
+	// below
+	extracted := filepath.Join(tmp, "theme")
+	if err := os.Mkdir(extracted, 0755); err != nil {
+		return nil, err
+	}
+	root, err := extractTheme(archive, extracted)
+	if err != nil {
+		return nil, err
+	}
+	if m, err := ReadManifest(os.DirFS(root)); err == nil && IsBuiltin(m.Name) {
+		return nil, fmt.Errorf("theme %q is built in; pick another name and set parent: %s to extend it", m.Name, m.Name)
+	}
+	m, err := Validate(os.DirFS(root), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(dir, m.Name)
+	previous := ""
+	if _, err := os.Stat(target); err == nil {
+		if !replace {
+			return nil, fmt.Errorf("theme %q is already installed", m.Name)
+		}
+		previous = filepath.Join(tmp, "previous")
+		if err := os.Rename(target, previous); err != nil {
+			return nil, fmt.Errorf("failed to replace theme: %w", err)
+		}
+	}
+
+	if err := os.Rename(root, target); err != nil {
+		if previous != "" {
+			os.Rename(previous, target)
+		}
+		return nil, fmt.Errorf("failed to install theme: %w", err)
+	}
+	return m, nil
+}
+
+// ValidateArchive checks the theme in an archive without installing it,
+// resolving its parent in dir. See Install and Validate.
+func ValidateArchive(archive, dir string) (*Manifest, error) {
+	tmp, err := os.MkdirTemp("", "theme-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	root, err := extractTheme(archive, tmp)
+	if err != nil {
+		return nil, err
+	}
+	return Validate(os.DirFS(root), dir)
+}
+
+// Remove uninstalls the named theme from dir. For a built-in theme this
+// removes the files overriding it. Themes still inheriting from it block
+// the removal.
+func Remove(name, dir string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid theme %q", name)
+	}
+
+	target := filepath.Join(dir, name)
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		if IsBuiltin(name) {
+			return fmt.Errorf("theme %q is built in and cannot be removed", name)
+		}
+		return fmt.Errorf("theme %q is not installed", name)
+	}
+
+	list, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range list {
+		if info.Parent == name && !IsBuiltin(name) {
+			return fmt.Errorf("theme %q inherits from %q; remove it first", info.Name, name)
+		}
+	}
+
+	return os.RemoveAll(target)
+}
+
+// IsArchive reports whether path names a supported theme archive
+func IsArchive(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// extractTheme extracts archive into dest and returns the directory
+// holding the theme's manifest
+func extractTheme(archive, dest string) (string, error) {
+	var err error
+	switch lower := strings.ToLower(archive); {
+	case strings.HasSuffix(lower, ".zip"):
+		err = extractZip(archive, dest)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		err = extractTarGz(archive, dest)
+	default:
+		return "", fmt.Errorf("unsupported archive %s (want .zip, .tar.gz or .tgz)", archive)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to extract %s: %w", archive, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, ManifestFile)); err == nil {
+		return dest, nil
+	}
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root := filepath.Join(dest, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(root, ManifestFile)); err == nil {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no %s at its root or in a single top-level directory", archive, ManifestFile)
+}
+
+// extractor writes archive entries below dest, rejecting paths leaving it
+// and stopping once maxExtractedSize bytes have been written
+type extractor struct {
+	dest    string
+	written int64
+}
+
+// path returns where the entry name goes
+func (x *extractor) path(name string) (string, error) {
+	// Archives created from "." prefix their entries with "./"
+	name = strings.TrimPrefix(strings.TrimSuffix(name, "/"), "./")
+	if !fs.ValidPath(name) || strings.Contains(name, `\`) {
+		return "", fmt.Errorf("invalid path %q in archive", name)
+	}
+	return filepath.Join(x.dest, filepath.FromSlash(name)), nil
+}
+
+func (x *extractor) dir(name string) error {
+	path, err := x.path(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0755)
+}
+
+func (x *extractor) file(name string, r io.Reader) error {
+	path, err := x.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, maxExtractedSize-x.written+1))
+	x.written += n
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && x.written > maxExtractedSize {
+		err = fmt.Errorf("archive contents exceed %d MiB", maxExtractedSize>>20)
+	}
+	return err
+}
+
+func extractZip(archive, dest string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	x := &extractor{dest: dest}
+	for _, f := range r.File {
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			err = x.dir(f.Name)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			if rc, err = f.Open(); err == nil {
+				err = x.file(f.Name, rc)
+				rc.Close()
+			}
+		default:
+			err = fmt.Errorf("unsupported entry %q: only files and directories are allowed", f.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarGz(archive, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	x := &extractor{dest: dest}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(hdr.Name)
+		case tar.TypeReg:
+			err = x.file(hdr.Name, tr)
+		default:
+			err = fmt.Errorf("unsupported entry %q: only files and directories are allowed", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
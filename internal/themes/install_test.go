@@ -0,0 +1,177 @@
+package themes
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeZip writes an archive with the given files, by slash-separated path
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTarGz writes an archive with the given files, by slash-separated
+// path
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	tw := tar.NewWriter(zw)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	src := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "themes")
+
+	// A zip with the theme at its root
+	writeZip(t, filepath.Join(src, "ocean.zip"), map[string]string{
+		"theme.yaml":           "name: ocean\nversion: 1.0.0\nparent: default\n",
+		"static/css/ocean.css": "v1",
+	})
+	m, err := Install(filepath.Join(src, "ocean.zip"), dir, false)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if m.Name != "ocean" || m.Version != "1.0.0" {
+		t.Errorf("Install = %+v", m)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "ocean", "static", "css", "ocean.css")); err != nil || string(data) != "v1" {
+		t.Errorf("installed ocean.css = %q, %v", data, err)
+	}
+
+	// A tarball with the theme in a top-level directory, as created from
+	// "." by tar
+	writeTarGz(t, filepath.Join(src, "ocean-2.tgz"), map[string]string{
+		"./ocean-2.0.0/theme.yaml":           "name: ocean\nversion: 2.0.0\nparent: default\n",
+		"./ocean-2.0.0/static/css/ocean.css": "v2",
+	})
+	if _, err := Install(filepath.Join(src, "ocean-2.tgz"), dir, false); err == nil || !strings.Contains(err.Error(), "already installed") {
+		t.Errorf("Install over an installed theme = %v, want already installed", err)
+	}
+	if _, err := Install(filepath.Join(src, "ocean-2.tgz"), dir, true); err != nil {
+		t.Fatalf("Install with replace failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "ocean", "static", "css", "ocean.css")); err != nil || string(data) != "v2" {
+		t.Errorf("replaced ocean.css = %q, %v", data, err)
+	}
+
+	// A theme extending the installed one
+	writeZip(t, filepath.Join(src, "reef.zip"), map[string]string{
+		"theme.yaml": "name: reef\nparent: ocean\n",
+	})
+	if _, err := Install(filepath.Join(src, "reef.zip"), dir, false); err != nil {
+		t.Fatalf("Install reef failed: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("themes dir holds %d entries, want ocean and reef only", len(entries))
+	}
+
+	if err := Remove("ocean", dir); err == nil || !strings.Contains(err.Error(), "inherits from") {
+		t.Errorf("Remove(ocean) = %v, want an error about reef inheriting from it", err)
+	}
+	if err := Remove("reef", dir); err != nil {
+		t.Errorf("Remove(reef) failed: %v", err)
+	}
+	if err := Remove("ocean", dir); err != nil {
+		t.Errorf("Remove(ocean) failed: %v", err)
+	}
+	if Exists("ocean", dir) {
+		t.Error("ocean still exists after removal")
+	}
+	if err := Remove("dark", dir); err == nil || !strings.Contains(err.Error(), "built in") {
+		t.Errorf("Remove(dark) = %v, want built-in error", err)
+	}
+}
+
+func TestInstallRejectsBadArchives(t *testing.T) {
+	src := t.TempDir()
+	dir := t.TempDir()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{"traversal.zip", map[string]string{
+			"theme.yaml":    "name: evil\nparent: default\n",
+			"../escape.txt": "x",
+		}, "invalid path"},
+		{"absolute.tgz", map[string]string{
+			"theme.yaml":      "name: evil\nparent: default\n",
+			"/tmp/escape.txt": "x",
+		}, "invalid path"},
+		{"builtin.zip", map[string]string{
+			"theme.yaml": "name: dark\n",
+		}, "built in"},
+		{"nomanifest.zip", map[string]string{
+			"pages/index.html": "<h1></h1>",
+		}, "no theme.yaml"},
+		{"invalid.zip", map[string]string{
+			"theme.yaml": "name: broken\n",
+		}, `page "index"`},
+		{"theme.rar", nil, "unsupported archive"},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(src, test.name)
+		switch {
+		case strings.HasSuffix(test.name, ".zip"):
+			writeZip(t, path, test.files)
+		case strings.HasSuffix(test.name, ".tgz"):
+			writeTarGz(t, path, test.files)
+		}
+
+		if _, err := Install(path, dir, false); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: Install = %v, want error containing %q", test.name, err, test.err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); err == nil {
+		t.Error("archive escaped the themes directory")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("themes dir holds %d entries after failed installs, want none", len(entries))
+	}
+}
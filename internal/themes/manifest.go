@@ -0,0 +1,129 @@
+package themes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the name of the manifest in a theme's directory
+const ManifestFile = "theme.yaml"
+
+// Manifest describes a theme
+type Manifest struct {
+	Name        string   `yaml:"name"`
+	Version     string   `yaml:"version"`
+	Author      string   `yaml:"author"`
+	Description string   `yaml:"description"`
+	Parent      string   `yaml:"parent"` // Theme to inherit missing files from
+	Pages       []string `yaml:"pages"`  // Pages the theme must render, e.g. "index"; default ["index"]
+}
+
+// RequiredPages returns the pages the theme must render
+func (m *Manifest) RequiredPages() []string {
+	if len(m.Pages) == 0 {
+		return []string{"index"}
+	}
+	return m.Pages
+}
+
+// ReadManifest reads and checks the manifest at the root of fsys. The
+// error wraps fs.ErrNotExist if there is none.
+func ReadManifest(fsys fs.FS) (*Manifest, error) {
+	data, err := fs.ReadFile(fsys, ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	if err := m.check(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	return &m, nil
+}
+
+// check validates the fields of m on their own
+func (m *Manifest) check() error {
+	if m.Name == "" {
+		return errors.New("name is required")
+	}
+	if !ValidName(m.Name) {
+		return fmt.Errorf("invalid name %q", m.Name)
+	}
+	if m.Parent != "" && !ValidName(m.Parent) {
+		return fmt.Errorf("invalid parent %q", m.Parent)
+	}
+	if m.Parent == m.Name {
+		return fmt.Errorf("theme %q cannot be its own parent", m.Name)
+	}
+	for _, page := range m.Pages {
+		if !fs.ValidPath(page) || page == "." || strings.HasSuffix(page, ".html") {
+			return fmt.Errorf("invalid page %q (want a name such as \"index\")", page)
+		}
+	}
+	return nil
+}
+
+// Validate checks a theme whose own files are fsys: it must have a valid
+// manifest, its parent must be available in dir or built in without
+// inheriting from the theme again, and every required page must render
+// with the theme's layouts and partials, its own or inherited.
+func Validate(fsys fs.FS, dir string) (*Manifest, error) {
+	m, err := ReadManifest(fsys)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("missing %s", ManifestFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	full := overlayFS{fsys}
+	if m.Parent != "" {
+		if !Exists(m.Parent, dir) {
+			return nil, fmt.Errorf("parent theme %q is not available", m.Parent)
+		}
+		layers, chain, err := resolve(m.Parent, dir)
+		if err != nil {
+			return nil, fmt.Errorf("parent theme %q: %w", m.Parent, err)
+		}
+		for _, name := range chain {
+			if name == m.Name {
+				return nil, fmt.Errorf("theme %q inherits from itself through %q", m.Name, m.Parent)
+			}
+		}
+		full = append(full, layers...)
+	}
+
+	for _, page := range m.RequiredPages() {
+		if _, err := ParsePage(full, page); err != nil {
+			return nil, fmt.Errorf("page %q: %w", page, err)
+		}
+	}
+	return m, nil
+}
+
+// ValidateInstalled checks the named theme as found in dir or built in,
+// see Validate
+func ValidateInstalled(name, dir string) (*Manifest, error) {
+	if !Exists(name, dir) {
+		return nil, fmt.Errorf("theme %q is not installed", name)
+	}
+
+	m, err := Validate(own(name, dir), dir)
+	if err != nil {
+		return nil, err
+	}
+	if m.Name != name {
+		return nil, fmt.Errorf("manifest names the theme %q, but its directory is %q", m.Name, name)
+	}
+	return m, nil
+}
@@ -0,0 +1,64 @@
+package themes
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"strings"
+)
+
+// LayoutTemplate is the template a theme defines in its layouts directory
+// to wrap its pages
+const LayoutTemplate = "layout"
+
+// Funcs are the functions available to theme templates. The asset
+// function maps a path below the theme's static directory to its URL, e.g.
+// {{asset "css/style.css"}}; the server replaces it with one returning
+// fingerprinted URLs.
+var Funcs = template.FuncMap{
+	"asset": func(name string) string {
+		return "/static/" + strings.TrimPrefix(name, "/")
+	},
+}
+
+// TemplateFiles returns the files ParsePage parses for page name, in the
+// order they are parsed. The page comes last, so that its definitions
+// replace defaults the layout provides with {{block}}.
+func TemplateFiles(fsys fs.FS, name string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{"layouts/*.html", "partials/*.html", "pages/" + name + ".html"} {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// ParsePage parses pages/<name>.html of a theme's files together with the
+// templates in its layouts and partials directories, so the page can use
+// what they define. If they define a "layout" template, that template is
+// returned for rendering the page, which then typically defines the
+// "title" and "content" templates the layout includes; otherwise the page
+// itself is returned.
+func ParsePage(fsys fs.FS, name string) (*template.Template, error) {
+	page := "pages/" + name + ".html"
+	if _, err := fs.Stat(fsys, page); err != nil {
+		return nil, fmt.Errorf("no page %q: %w", name, err)
+	}
+
+	files, err := TemplateFiles(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(name+".html").Funcs(Funcs).ParseFS(fsys, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	if layout := tmpl.Lookup(LayoutTemplate); layout != nil {
+		return layout, nil
+	}
+	return tmpl, nil
+}
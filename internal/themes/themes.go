@@ -0,0 +1,247 @@
+// Package themes finds, reads, validates and installs web interface
+// themes. Themes are either built into the binary or live in a themes
+// directory, one subdirectory per theme, whose files take precedence over
+// those of a built-in theme of the same name. A theme may name a parent in
+// its manifest and inherit the files it does not provide itself.
+package themes
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	webassets "github.com/yourusername/stroganoff/web"
+)
+
+// maxDepth bounds inheritance chains
+const maxDepth = 8
+
+// builtin holds the themes compiled into the binary, one directory per
+// theme
+var builtin = mustSub(webassets.Themes, "themes")
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// ValidName reports whether name can name a theme directory. Names
+// starting with a dot are reserved for hidden and temporary directories.
+func ValidName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`)
+}
+
+// IsBuiltin reports whether name is a theme compiled into the binary
+func IsBuiltin(name string) bool {
+	if !ValidName(name) {
+		return false
+	}
+	info, err := fs.Stat(builtin, name)
+	return err == nil && info.IsDir()
+}
+
+// own returns the files of the named theme itself, without those
+// inherited from its parent: the files in dir/<name> over the built-in
+// ones
+func own(name, dir string) overlayFS {
+	var layers overlayFS
+	if dir != "" {
+		layers = append(layers, os.DirFS(filepath.Join(dir, name)))
+	}
+	return append(layers, mustSub(builtin, name))
+}
+
+// resolve returns the layers of the named theme followed by those of its
+// ancestors, and the names along the inheritance chain
+func resolve(name, dir string) (overlayFS, []string, error) {
+	var layers overlayFS
+	var chain []string
+
+	for name != "" {
+		if !ValidName(name) {
+			return nil, nil, fmt.Errorf("invalid theme %q", name)
+		}
+		for _, seen := range chain {
+			if seen == name {
+				return nil, nil, fmt.Errorf("theme %q inherits from itself", name)
+			}
+		}
+		if len(chain) == maxDepth {
+			return nil, nil, fmt.Errorf("theme %q has more than %d ancestors", chain[0], maxDepth-1)
+		}
+		chain = append(chain, name)
+
+		fsys := own(name, dir)
+		layers = append(layers, fsys...)
+
+		m, err := ReadManifest(fsys)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		name = m.Parent
+	}
+
+	return layers, chain, nil
+}
+
+// FS returns the files of the named theme. Files in dir/<name> take
+// precedence over the built-in files of the theme, which take precedence
+// over the files of its parent, and so on up the inheritance chain. dir
+// may be empty to use built-in themes only.
+func FS(name, dir string) (fs.FS, error) {
+	layers, _, err := resolve(name, dir)
+	if err != nil {
+		return nil, err
+	}
+	return layers, nil
+}
+
+// Info describes an available theme
+type Info struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Description string `json:"description,omitempty"`
+	Parent      string `json:"parent,omitempty"`
+	Builtin     bool   `json:"builtin"`  // Compiled into the binary
+	External    bool   `json:"external"` // Found in the themes directory; for built-in themes its files override theirs
+}
+
+// List returns the built-in themes and those in dir, sorted by name. A
+// missing dir is treated as empty. Themes whose manifest cannot be read
+// are listed with their name only; see Validate.
+func List(dir string) ([]Info, error) {
+	found := make(map[string]*Info)
+	add := func(fsys fs.FS, builtin bool) error {
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || !ValidName(entry.Name()) {
+				continue
+			}
+			info, ok := found[entry.Name()]
+			if !ok {
+				info = &Info{Name: entry.Name()}
+				found[entry.Name()] = info
+			}
+			if builtin {
+				info.Builtin = true
+			} else {
+				info.External = true
+			}
+		}
+		return nil
+	}
+
+	if err := add(builtin, true); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := add(os.DirFS(dir), false); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	list := make([]Info, 0, len(found))
+	for _, info := range found {
+		if m, err := ReadManifest(own(info.Name, dir)); err == nil {
+			info.Version = m.Version
+			info.Author = m.Author
+			info.Description = m.Description
+			info.Parent = m.Parent
+		}
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// Names returns the names of the built-in themes and those in dir, sorted
+func Names(dir string) ([]string, error) {
+	list, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(list))
+	for i, info := range list {
+		names[i] = info.Name
+	}
+	return names, nil
+}
+
+// Exists reports whether the named theme is built in or found in dir
+func Exists(name, dir string) bool {
+	if IsBuiltin(name) {
+		return true
+	}
+	if !ValidName(name) || dir == "" {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dir, name))
+	return err == nil && info.IsDir()
+}
+
+// overlayFS combines file systems. A file is read from the first file
+// system that has it; directory listings are merged.
+type overlayFS []fs.FS
+
+// Open implements fs.FS
+func (o overlayFS) Open(name string) (fs.File, error) {
+	for _, fsys := range o {
+		f, err := fsys.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements fs.ReadDirFS
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := make(map[string]bool)
+	found := false
+
+	for _, fsys := range o {
+		list, err := fs.ReadDir(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, entry := range list {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
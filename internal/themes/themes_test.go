@@ -0,0 +1,147 @@
+package themes
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTheme writes files, given by slash-separated path, into dir
+func writeTheme(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuiltinThemesAreValid(t *testing.T) {
+	for _, name := range []string{"default", "dark"} {
+		if _, err := ValidateInstalled(name, ""); err != nil {
+			t.Errorf("built-in theme %s: %v", name, err)
+		}
+	}
+}
+
+func TestInheritance(t *testing.T) {
+	dir := t.TempDir()
+	writeTheme(t, dir, map[string]string{
+		"ocean/theme.yaml":             "name: ocean\nversion: 2.0.0\nauthor: Jane\nparent: dark\n",
+		"ocean/static/css/ocean.css":   "ocean",
+		"ocean/static/css/style.css":   "own style",
+		"reef/theme.yaml":              "name: reef\nparent: ocean\n",
+		"reef/partials/footer.html":    `{{define "footer"}}reef{{end}}`,
+		"loop-a/theme.yaml":            "name: loop-a\nparent: loop-b\n",
+		"loop-b/theme.yaml":            "name: loop-b\nparent: loop-a\n",
+		"default/static/css/extra.css": "override",
+	})
+
+	fsys, err := FS("reef", dir)
+	if err != nil {
+		t.Fatalf("FS failed: %v", err)
+	}
+	for name, want := range map[string]string{
+		"static/css/ocean.css": "ocean",     // From the parent
+		"static/css/style.css": "own style", // The parent's file over the grandparent's
+		"partials/footer.html": `{{define "footer"}}reef{{end}}`,
+	} {
+		if data, err := fs.ReadFile(fsys, name); err != nil || string(data) != want {
+			t.Errorf("reef %s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+	// From the built-in grandparent
+	if data, err := fs.ReadFile(fsys, "static/css/theme-dark.css"); err != nil || len(data) == 0 {
+		t.Errorf("reef theme-dark.css = %v, want the built-in file", err)
+	}
+	if _, err := ParsePage(fsys, "index"); err != nil {
+		t.Errorf("ParsePage(reef, index) failed: %v", err)
+	}
+
+	if _, err := FS("loop-a", dir); err == nil || !strings.Contains(err.Error(), "inherits from itself") {
+		t.Errorf("FS(loop-a) = %v, want an inheritance loop error", err)
+	}
+
+	list, err := List(dir)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var names []string
+	for _, info := range list {
+		names = append(names, info.Name)
+		switch info.Name {
+		case "ocean":
+			if info.Version != "2.0.0" || info.Author != "Jane" || info.Parent != "dark" || info.Builtin || !info.External {
+				t.Errorf("List ocean = %+v", info)
+			}
+		case "default":
+			if !info.Builtin || !info.External || info.Version != "1.0.0" {
+				t.Errorf("List default = %+v", info)
+			}
+		}
+	}
+	if want := []string{"dark", "default", "loop-a", "loop-b", "ocean", "reef"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List names = %v, want %v", names, want)
+	}
+
+	if !Exists("reef", dir) || !Exists("dark", "") || Exists("reef", "") || Exists("..", dir) || Exists(".hidden", dir) {
+		t.Error("Exists reports wrong results")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string // Expected error substring, "" for valid themes
+	}{
+		{"valid", map[string]string{
+			"theme.yaml":       "name: plain\nversion: 1.0.0\n",
+			"pages/index.html": "<h1>{{.AppName}}</h1>",
+		}, ""},
+		{"inherited pages", map[string]string{
+			"theme.yaml": "name: child\nparent: default\npages: [index]\n",
+		}, ""},
+		{"missing manifest", map[string]string{
+			"pages/index.html": "<h1></h1>",
+		}, "missing theme.yaml"},
+		{"unknown manifest key", map[string]string{
+			"theme.yaml":       "name: x\nparnet: default\n",
+			"pages/index.html": "<h1></h1>",
+		}, "invalid theme.yaml"},
+		{"missing name", map[string]string{
+			"theme.yaml": "version: 1.0.0\nparent: default\n",
+		}, "name is required"},
+		{"unknown parent", map[string]string{
+			"theme.yaml": "name: x\nparent: nope\n",
+		}, `parent theme "nope" is not available`},
+		{"missing page", map[string]string{
+			"theme.yaml":       "name: x\npages: [index, about]\n",
+			"pages/index.html": "<h1></h1>",
+		}, `page "about"`},
+		{"broken template", map[string]string{
+			"theme.yaml":        "name: x\nparent: default\n",
+			"partials/nav.html": `{{define "nav"}}{{if}}{{end}}`,
+		}, `page "index"`},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		writeTheme(t, dir, test.files)
+
+		_, err := Validate(os.DirFS(dir), "")
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: Validate failed: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: Validate = %v, want error containing %q", test.name, err, test.err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 )
 
@@ -55,6 +56,8 @@ func (gc *GithubClient) getRelease(url string) (*Release, error) {
 		req.Header.Set("Authorization", "token "+gc.token)
 	}
 
+	slog.Debug("Fetching release", "url", url)
+
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/pkg/auth"
+)
+
+const (
+	// adminPrefix is the path prefix of the admin endpoints
+	adminPrefix = "/api/admin"
+
+	// adminScope is the token scope required for admin endpoints
+	adminScope = auth.AdminScope
+)
+
+// adminMiddleware restricts admin endpoints to tokens with the admin scope.
+// Without authentication there are no such tokens, so only local clients
+// are let through.
+func (s *Server) adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.GetInstance().GetAPI().AuthEnabled {
+			if !isLocalRequest(c.Request) {
+				abortWithError(c, http.StatusForbidden, "Admin endpoints are only available to local clients unless authentication is enabled")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if !s.authenticator.HasScope(c.GetString("token"), adminScope) {
+			abortWithError(c, http.StatusForbidden, "Admin scope required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// isLocalRequest reports whether r comes from a loopback address and did
+// not pass through a proxy, which would make remote clients look local.
+// The connection's address is used rather than gin's ClientIP, which
+// trusts forwarding headers.
+func isLocalRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return false
+	}
+	for _, header := range []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"} {
+		if r.Header.Get(header) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// revisionJSON renders a config revision with its secrets redacted
+func revisionJSON(rev config.Revision, current bool) gin.H {
+	return gin.H{
+		"version": rev.Version,
+		"time":    rev.Time,
+		"source":  rev.Source,
+		"hash":    rev.Hash,
+		"current": current,
+		"config":  rev.Config.Redacted(rev.Origins).Tree(),
+	}
+}
+
+func (s *Server) configHistoryHandler(c *gin.Context) {
+	history := config.GetInstance().History()
+
+	revisions := make([]gin.H, len(history))
+	for i, rev := range history {
+		revisions[i] = revisionJSON(rev, i == len(history)-1)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revisions": revisions,
+	})
+}
+
+func (s *Server) configRollbackHandler(c *gin.Context) {
+	var req struct {
+		Version int `json:"version"`
+	}
+
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	rev, err := config.GetInstance().Rollback(req.Version)
+	if err != nil {
+		abortWithError(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, revisionJSON(rev, true))
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/stroganoff/internal/config"
+)
+
+func TestAdminAccess(t *testing.T) {
+	call := func(server *Server, method, path, remoteAddr, token, body string, header http.Header) *httptest.ResponseRecorder {
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Content-Type", "application/json")
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+		req := newRequest(method, path, body, header)
+		req.RemoteAddr = remoteAddr
+		return serve(server, req)
+	}
+	const history = adminPrefix + "/config/history"
+
+	// Without authentication only local clients reach admin endpoints
+	if err := config.GetInstance().Load([]byte("api:\n  auth_enabled: false\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	server := NewServer()
+	defer server.Stop()
+
+	for _, test := range []struct {
+		remoteAddr string
+		header     http.Header
+		want       int
+	}{
+		{"127.0.0.1:5000", nil, http.StatusOK},
+		{"[::1]:5000", nil, http.StatusOK},
+		{"192.0.2.1:5000", nil, http.StatusForbidden},
+		{"127.0.0.1:5000", http.Header{"X-Forwarded-For": {"192.0.2.1"}}, http.StatusForbidden},
+	} {
+		if code := call(server, http.MethodGet, history, test.remoteAddr, "", "", test.header).Code; code != test.want {
+			t.Errorf("GET history from %s %v without auth = %d, want %d", test.remoteAddr, test.header, code, test.want)
+		}
+	}
+	if code := call(server, http.MethodPost, "/api/auth/token", "192.0.2.1:5000", "", `{"scopes": ["admin"]}`, nil).Code; code != http.StatusForbidden {
+		t.Errorf("creating an admin token without auth = %d, want 403", code)
+	}
+
+	// With authentication the admin scope is required, from anywhere. The
+	// configured admin token bootstraps the other tokens.
+	bootstrap := strings.Repeat("b", 32)
+	if err := config.GetInstance().Load([]byte("api:\n  auth_enabled: true\n  admin_token: " + bootstrap + "\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	createToken := func(scopes string) string {
+		t.Helper()
+		rec := call(server, http.MethodPost, "/api/auth/token", "192.0.2.1:5000", bootstrap, `{"scopes": `+scopes+`}`, nil)
+		var resp struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); rec.Code != http.StatusOK || err != nil || resp.Token == "" {
+			t.Fatalf("creating a token with scopes %s = %d %s", scopes, rec.Code, rec.Body.String())
+		}
+		return resp.Token
+	}
+	user := createToken(`["read"]`)
+	admin := createToken(`["admin"]`)
+
+	for _, test := range []struct {
+		remoteAddr, token string
+		want              int
+	}{
+		{"127.0.0.1:5000", "", http.StatusUnauthorized},
+		{"127.0.0.1:5000", user, http.StatusForbidden},
+		{"192.0.2.1:5000", admin, http.StatusOK},
+		{"192.0.2.1:5000", bootstrap, http.StatusOK},
+		{"192.0.2.1:5000", bootstrap[1:], http.StatusUnauthorized},
+	} {
+		if code := call(server, http.MethodGet, history, test.remoteAddr, test.token, "", nil).Code; code != test.want {
+			t.Errorf("GET history from %s with auth = %d, want %d", test.remoteAddr, code, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		token, scopes string
+		want          int
+	}{
+		{user, `["read"]`, http.StatusOK},
+		{user, `["read", "admin"]`, http.StatusForbidden},
+		{admin, `["admin"]`, http.StatusOK},
+	} {
+		if code := call(server, http.MethodPost, "/api/auth/token", "192.0.2.1:5000", test.token, `{"scopes": `+test.scopes+`}`, nil).Code; code != test.want {
+			t.Errorf("creating a token with scopes %s = %d, want %d", test.scopes, code, test.want)
+		}
+	}
+}
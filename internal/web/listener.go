@@ -0,0 +1,298 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/stroganoff/internal/config"
+)
+
+// listenerSettings are the server settings baked into the listeners when
+// they start. Changing any of them rebinds the listeners; the other server
+// settings are read for every request.
+var listenerSettings = []string{
+	"server.host",
+	"server.port",
+	"server.tls_cert",
+	"server.tls_key",
+	"server.tls_min_version",
+	"server.tls_cipher_suites",
+	"server.http_redirect_port",
+	"server.read_timeout",
+	"server.read_header_timeout",
+	"server.write_timeout",
+	"server.idle_timeout",
+	"server.max_header_bytes",
+}
+
+// socket is a bound TCP listener whose connections can be handed from one
+// http.Server to the next, so that a server can be replaced without
+// closing its port
+type socket struct {
+	ln       net.Listener
+	accepted chan acceptResult
+	closing  chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// listen binds addr and starts accepting connections on it
+func listen(addr string) (*socket, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sock := &socket{
+		ln:       ln,
+		accepted: make(chan acceptResult),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go sock.acceptLoop()
+	return sock, nil
+}
+
+// acceptLoop passes accepted connections and errors to whichever server
+// currently accepts through a view of the socket
+func (sock *socket) acceptLoop() {
+	defer close(sock.done)
+	for {
+		conn, err := sock.ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		select {
+		case sock.accepted <- acceptResult{conn, err}:
+		case <-sock.closing:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// view returns a listener accepting connections from the socket. Closing
+// the view leaves the socket open.
+func (sock *socket) view() net.Listener {
+	return &socketView{sock: sock, closed: make(chan struct{})}
+}
+
+// Close closes the socket. Connections already accepted stay open.
+func (sock *socket) Close() error {
+	var err error
+	sock.once.Do(func() {
+		close(sock.closing)
+		err = sock.ln.Close()
+	})
+	return err
+}
+
+// socketView is a net.Listener for a single server using a socket
+type socketView struct {
+	sock   *socket
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Accept implements net.Listener
+func (v *socketView) Accept() (net.Conn, error) {
+	select {
+	case <-v.closed:
+		return nil, net.ErrClosed
+	default:
+	}
+
+	select {
+	case r := <-v.sock.accepted:
+		return r.conn, r.err
+	case <-v.closed:
+		return nil, net.ErrClosed
+	case <-v.sock.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener
+func (v *socketView) Close() error {
+	v.once.Do(func() { close(v.closed) })
+	return nil
+}
+
+// Addr implements net.Listener
+func (v *socketView) Addr() net.Addr {
+	return v.sock.ln.Addr()
+}
+
+// listenerSet is the group of http.Servers started for one server config:
+// the main server and, with TLS, the optional redirect server
+type listenerSet struct {
+	servers []*http.Server
+	tls     bool // Whether the first server serves HTTPS
+}
+
+// newListenerSet builds the servers for cfg without starting them
+func (s *Server) newListenerSet(cfg config.ServerConfig) (*listenerSet, error) {
+	httpServer := newHTTPServer(cfg, cfg.Port, s.engine)
+	set := &listenerSet{servers: []*http.Server{httpServer}}
+
+	if cfg.TLSCert != "" {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		httpServer.TLSConfig = tlsConfig
+		set.tls = true
+
+		if cfg.HTTPRedirectPort != 0 {
+			set.servers = append(set.servers, newHTTPServer(cfg, cfg.HTTPRedirectPort, redirectHandler(cfg.Port)))
+		}
+	}
+
+	return set, nil
+}
+
+// String describes the addresses served by the set
+func (set *listenerSet) String() string {
+	addrs := make([]string, len(set.servers))
+	for i, srv := range set.servers {
+		scheme := "http"
+		if i == 0 && set.tls {
+			scheme = "https"
+		}
+		addrs[i] = scheme + "://" + srv.Addr
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// shutdown gracefully stops the servers, closing connections that are
+// still open when ctx expires
+func (set *listenerSet) shutdown(ctx context.Context) error {
+	var err error
+	for _, srv := range set.servers {
+		if shutdownErr := srv.Shutdown(ctx); shutdownErr != nil {
+			srv.Close()
+			if err == nil {
+				err = shutdownErr
+			}
+		}
+	}
+	return err
+}
+
+// bind starts serving cfg and makes it the current listener set. Sockets
+// on addresses that did not change are handed over to the new servers,
+// other addresses are bound anew. It returns the previous set, which the
+// caller must shut down. If anything fails, the current set keeps serving
+// unchanged. It must be called with s.mu held.
+func (s *Server) bind(cfg config.ServerConfig) (*listenerSet, error) {
+	set, err := s.newListenerSet(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sockets := make(map[string]*socket)
+	for _, srv := range set.servers {
+		if sock, ok := s.sockets[srv.Addr]; ok {
+			sockets[srv.Addr] = sock
+			continue
+		}
+		sock, err := listen(srv.Addr)
+		if err != nil {
+			for addr, sock := range sockets {
+				if s.sockets[addr] != sock {
+					sock.Close()
+				}
+			}
+			return nil, err
+		}
+		sockets[srv.Addr] = sock
+	}
+
+	for i, srv := range set.servers {
+		go s.serve(srv, sockets[srv.Addr].view(), i == 0 && set.tls)
+	}
+
+	// Sockets no longer used stop accepting; the previous servers keep
+	// the connections they already accepted until they are shut down
+	for addr, sock := range s.sockets {
+		if _, ok := sockets[addr]; !ok {
+			sock.Close()
+		}
+	}
+
+	previous := s.current
+	s.current = set
+	s.sockets = sockets
+	return previous, nil
+}
+
+// serve runs srv on ln and reports failures other than being shut down or
+// having its socket closed
+func (s *Server) serve(srv *http.Server, ln net.Listener, useTLS bool) {
+	var err error
+	if useTLS {
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		err = srv.Serve(ln)
+	}
+
+	if !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+		select {
+		case s.errCh <- err:
+		default:
+		}
+	}
+}
+
+// rebind moves the server to the listener settings of a new configuration.
+// The new listeners start before the previous ones drain, so no requests
+// are refused while switching. If the new settings cannot be applied, e.g.
+// because the port is in use, the error is reported and the server keeps
+// serving on the previous listeners.
+func (s *Server) rebind(change config.Change) {
+	changed := false
+	for _, path := range listenerSettings {
+		changed = changed || change.Changed(path)
+	}
+	if !changed {
+		return
+	}
+
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return
+	}
+	previous, err := s.bind(change.New.Server)
+	current := s.current
+	s.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Failed to apply new listener settings, keeping the previous listeners", "addr", current.String(), "error", err)
+		return
+	}
+	slog.Info("Rebound listeners", "addr", current.String())
+
+	// Drain in the background so that other config subscribers are not
+	// held up by slow requests
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), change.New.Server.ShutdownTimeout.Duration())
+		defer cancel()
+		if err := previous.shutdown(ctx); err != nil {
+			slog.Warn("Previous listeners did not drain in time and were closed", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/internal/themes"
+	"github.com/yourusername/stroganoff/pkg/version"
+)
+
+// AppName is the application name shown in the web interface
+const AppName = "Stroganoff"
+
+// PageData is the data theme pages are rendered with
+type PageData struct {
+	AppName     string
+	Version     version.Info
+	Theme       string
+	Themes      []string // Available themes
+	Routes      []Route
+	Health      string // "healthy" or "draining"
+	AuthEnabled bool   // Whether token access levels are enforced
+	Year        int
+}
+
+// pageData returns the data for rendering a page of theme
+func (s *Server) pageData(theme string) PageData {
+	names, _ := GetAvailableThemes()
+	return PageData{
+		AppName:     AppName,
+		Version:     version.Get(),
+		Theme:       theme,
+		Themes:      names,
+		Routes:      s.Routes(),
+		Health:      s.healthStatus(),
+		AuthEnabled: config.GetInstance().GetAPI().AuthEnabled,
+		Year:        time.Now().Year(),
+	}
+}
+
+// pageCache keeps parsed theme pages. An entry is reused as long as the
+// theme's manifest and template files keep their names, sizes and
+// modification times, so edited and newly installed themes are picked up
+// without a restart.
+type pageCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedPage
+}
+
+// cachedPage is a parsed page with the stamp of the files it was parsed from
+type cachedPage struct {
+	stamp string
+	tmpl  *template.Template
+}
+
+// pageStamp describes the files page name is parsed from, see pageCache
+func pageStamp(fsys fs.FS, name string) (string, error) {
+	files, err := themes.TemplateFiles(fsys, name)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, file := range append([]string{themes.ManifestFile}, files...) {
+		info, err := fs.Stat(fsys, file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s\x00%d\x00%d\x00", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// page returns pages/<name>.html of theme parsed, see themes.ParsePage.
+// The asset template function returns fingerprinted URLs, see
+// staticFilesHandler.
+func (s *Server) page(theme, themesDir, name string) (*template.Template, error) {
+	fsys, err := themes.FS(theme, themesDir)
+	if err != nil {
+		return nil, err
+	}
+	stamp, err := pageStamp(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("theme %q: %w", theme, err)
+	}
+
+	key := theme + "\x00" + themesDir
+	cacheKey := key + "\x00" + name
+	s.pages.mu.Lock()
+	cached := s.pages.entries[cacheKey]
+	s.pages.mu.Unlock()
+	if cached != nil && cached.stamp == stamp {
+		return cached.tmpl, nil
+	}
+
+	tmpl, err := themes.ParsePage(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("theme %q: %w", theme, err)
+	}
+	tmpl.Funcs(template.FuncMap{
+		"asset": s.assetURL(fsys, key),
+	})
+
+	s.pages.mu.Lock()
+	if s.pages.entries == nil {
+		s.pages.entries = make(map[string]*cachedPage)
+	}
+	s.pages.entries[cacheKey] = &cachedPage{stamp: stamp, tmpl: tmpl}
+	s.pages.mu.Unlock()
+	return tmpl, nil
+}
+
+// renderPage renders pages/<name>.html of theme
+func (s *Server) renderPage(theme, themesDir, name string, data PageData) ([]byte, error) {
+	tmpl, err := s.page(theme, themesDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Render into a buffer, so that a failing template does not leave a
+	// partial page behind
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/pkg/version"
+)
+
+func TestIndexPage(t *testing.T) {
+	t.Run("data", func(t *testing.T) {
+		if err := config.GetInstance().Load([]byte("api:\n  auth_enabled: true\n")); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		server := NewServer()
+		defer server.Stop()
+
+		rec := get(server, "/", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET / = %d, want 200", rec.Code)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{
+			"<title>" + AppName + " - ",
+			"Welcome to " + AppName,
+			version.Get().Version,
+			"status-healthy",
+			"<code>GET /api/heartbeat</code>",
+			"<p>Get application metrics (requires auth)</p>",
+			"<p>List configuration revisions (requires admin scope)</p>",
+			"<p>Check application health status</p>",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("index page does not contain %q", want)
+			}
+		}
+		// Every registered route is listed
+		for _, route := range server.Routes() {
+			if !strings.Contains(body, "<code>"+route.Method+" "+route.Path+"</code>") {
+				t.Errorf("index page does not list %s %s", route.Method, route.Path)
+			}
+		}
+
+		// The page's assets load without a token, like the page itself
+		for _, path := range []string{"/static/css/style.css", "/static/js/app.js"} {
+			if rec := get(server, path, nil); rec.Code != http.StatusOK {
+				t.Errorf("GET %s with auth enabled = %d, want 200", path, rec.Code)
+			}
+		}
+	})
+
+	t.Run("partial override", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, "default/partials/footer.html", `{{define "footer"}}<footer>custom {{.Theme}}</footer>{{end}}`)
+
+		if err := config.GetInstance().Load([]byte("server:\n  themes_dir: " + dir + "\n")); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		server := NewServer()
+		defer server.Stop()
+
+		rec := get(server, "/", nil)
+		if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "<footer>custom default</footer>") || !strings.Contains(body, "Welcome to") {
+			t.Errorf("GET / = %d %q, want the built-in page with the custom footer", rec.Code, body)
+		}
+
+		// A broken template fails the page instead of rendering part of it
+		writeFile(t, dir, "default/partials/footer.html", `{{define "footer"}}{{.Missing}}{{end}}`)
+		if rec := get(server, "/", nil); rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "<html") {
+			t.Errorf("GET / with broken footer = %d %q, want 500", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestPageCache(t *testing.T) {
+	dir := t.TempDir()
+	if err := config.GetInstance().Load([]byte("server:\n  themes_dir: " + dir + "\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	server := NewServer()
+	defer server.Stop()
+
+	first, err := server.page("default", dir, "index")
+	if err != nil {
+		t.Fatalf("page failed: %v", err)
+	}
+	if again, _ := server.page("default", dir, "index"); again != first {
+		t.Error("unchanged page was parsed again")
+	}
+
+	// Installing a file into the themes directory replaces the cached page
+	path := writeFile(t, dir, "default/partials/footer.html", `{{define "footer"}}<footer>v1</footer>{{end}}`)
+	second, err := server.page("default", dir, "index")
+	if err != nil || second == first {
+		t.Fatalf("page after adding a partial = %v, want a new template", err)
+	}
+
+	// So does editing it, even without a change in size
+	writeFile(t, dir, "default/partials/footer.html", `{{define "footer"}}<footer>v2</footer>{{end}}`)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	third, err := server.page("default", dir, "index")
+	if err != nil || third == second {
+		t.Fatalf("page after editing a partial = %v, want a new template", err)
+	}
+	var buf strings.Builder
+	if err := third.Execute(&buf, server.pageData("default")); err != nil || !strings.Contains(buf.String(), "<footer>v2</footer>") {
+		t.Errorf("edited page = %v %q, want the new footer", err, buf.String())
+	}
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/stroganoff/internal/logging"
+)
+
+const (
+	// requestIDHeader carries the request ID in requests and responses
+	requestIDHeader = "X-Request-ID"
+
+	// traceparentHeader carries the W3C trace context
+	traceparentHeader = "traceparent"
+
+	// maxRequestIDLength bounds request IDs accepted from clients
+	maxRequestIDLength = 128
+)
+
+// requestIDKey is the request context key of the request ID
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside a
+// request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware identifies every request. The ID is taken from the
+// X-Request-ID header if the client sent a usable one, otherwise from the
+// trace ID of a W3C traceparent header, otherwise it is generated. It is
+// stored in the gin and request contexts, echoed in the X-Request-ID
+// response header and added to log records logged with the request
+// context.
+//
+// The trace context is continued: the response carries a traceparent
+// header with the client's trace ID, or a new one, and a span ID for this
+// request.
+func (s *Server) requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		traceID, flags, traced := parseTraceparent(c.GetHeader(traceparentHeader))
+		if !traced {
+			traceID, flags = randomHex(16), "00"
+		}
+		spanID := randomHex(8)
+
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = traceID
+		}
+
+		c.Set("request_id", id)
+		ctx := context.WithValue(c.Request.Context(), requestIDKey{}, id)
+		ctx = logging.WithAttrs(ctx,
+			slog.String("request_id", id),
+			slog.String("trace_id", traceID),
+			slog.String("span_id", spanID),
+		)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Header(requestIDHeader, id)
+		c.Header(traceparentHeader, "00-"+traceID+"-"+spanID+"-"+flags)
+
+		c.Next()
+	}
+}
+
+// validRequestID reports whether a client supplied request ID can be used:
+// it must be non-empty, not too long and printable ASCII without spaces
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTraceparent extracts the trace ID and flags from a version 00 W3C
+// traceparent header, "00-<trace-id>-<parent-id>-<flags>"
+func parseTraceparent(header string) (traceID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return "", "", false
+	}
+	traceID, parentID, flags := parts[1], parts[2], parts[3]
+	if !isLowerHex(traceID, 32) || !isLowerHex(parentID, 16) || !isLowerHex(flags, 2) {
+		return "", "", false
+	}
+	// All-zero IDs are invalid
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return "", "", false
+	}
+	return traceID, flags, true
+}
+
+// isLowerHex reports whether s is n lowercase hex digits
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/internal/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	var logs syncBuffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(logging.NewContextHandler(slog.NewJSONHandler(&logs, nil))))
+
+	if err := config.GetInstance().Load([]byte("api:\n  auth_enabled: true\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	server := NewServer()
+	defer server.Stop()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := []struct {
+		name        string
+		requestID   string
+		traceparent string
+		want        string
+	}{
+		{"client request ID", "client-42", "", "client-42"},
+		{"trace ID", "", "00-" + traceID + "-00f067aa0ba902b7-01", traceID},
+		{"invalid request ID", "has space", "", ""},
+		{"invalid traceparent", "", "00-" + strings.Repeat("0", 32) + "-00f067aa0ba902b7-01", ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/heartbeat", nil)
+		if test.requestID != "" {
+			req.Header.Set(requestIDHeader, test.requestID)
+		}
+		if test.traceparent != "" {
+			req.Header.Set(traceparentHeader, test.traceparent)
+		}
+		rec := serve(server, req)
+
+		id := rec.Header().Get(requestIDHeader)
+		if test.want != "" && id != test.want {
+			t.Fatalf("%s: request ID %q, want %q", test.name, id, test.want)
+		}
+		if test.want == "" && (len(id) != 32 || id == test.requestID) {
+			t.Fatalf("%s: request ID %q, want a generated one", test.name, id)
+		}
+
+		traceparent := rec.Header().Get(traceparentHeader)
+		if _, _, ok := parseTraceparent(traceparent); !ok {
+			t.Fatalf("%s: invalid traceparent %q in response", test.name, traceparent)
+		}
+		if test.want == traceID && !strings.HasPrefix(traceparent, "00-"+traceID+"-") {
+			t.Fatalf("%s: traceparent %q does not continue the trace", test.name, traceparent)
+		}
+
+		// Auth is enabled, so the request is rejected with an error body
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusUnauthorized || body["request_id"] != id {
+			t.Fatalf("%s: got %d %v, want 401 with request_id %q", test.name, rec.Code, body, id)
+		}
+
+		if !strings.Contains(logs.String(), `"request_id":"`+id+`"`) {
+			t.Fatalf("%s: request ID missing from logs:\n%s", test.name, logs.String())
+		}
+	}
+}
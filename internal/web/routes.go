@@ -0,0 +1,73 @@
+package web
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Access levels of routes
+const (
+	accessPublic = "public" // Never requires a token
+	accessToken  = "token"  // Requires a token when api.auth_enabled is set
+	accessAdmin  = "admin"  // Requires a token with the admin scope, or a local client without api.auth_enabled
+)
+
+// publicPaths are exempt from authentication
+var publicPaths = map[string]bool{
+	"/health":     true,
+	"/":           true,
+	"/api/themes": true,
+}
+
+// publicPrefixes are path prefixes exempt from authentication. Theme static
+// files are public so that the public pages render with their styles and
+// scripts.
+var publicPrefixes = []string{"/static/"}
+
+// isPublicPath reports whether requests for p skip authentication
+func isPublicPath(p string) bool {
+	if publicPaths[p] {
+		return true
+	}
+	for _, prefix := range publicPrefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Route describes a registered route, e.g. for listing it in the web
+// interface
+type Route struct {
+	Method      string
+	Path        string
+	Description string
+	Access      string // accessPublic, accessToken or accessAdmin
+}
+
+// handle registers a route on group and records it in the route registry
+func (s *Server) handle(group *gin.RouterGroup, method, relativePath, description string, handler gin.HandlerFunc) {
+	group.Handle(method, relativePath, handler)
+
+	route := Route{
+		Method:      method,
+		Path:        path.Join(group.BasePath(), relativePath),
+		Description: description,
+		Access:      accessToken,
+	}
+	switch {
+	case isPublicPath(route.Path):
+		route.Access = accessPublic
+	case group.BasePath() == adminPrefix:
+		route.Access = accessAdmin
+	}
+	s.routes = append(s.routes, route)
+}
+
+// Routes returns the registered routes in registration order
+func (s *Server) Routes() []Route {
+	return append([]Route(nil), s.routes...)
+}
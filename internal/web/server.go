@@ -1,10 +1,15 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"runtime"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,10 +20,21 @@ import (
 
 // Server represents the HTTP server
 type Server struct {
-	engine      *gin.Engine
-	limiter     *ratelimit.Limiter
+	engine        *gin.Engine
+	limiter       *ratelimit.Limiter
 	authenticator *auth.Authenticator
-	config      *config.Config
+	config        *config.Config
+	routes        []Route
+	assets        assetCache
+	pages         pageCache
+
+	mu           sync.Mutex
+	current      *listenerSet
+	sockets      map[string]*socket
+	errCh        chan error
+	shuttingDown bool
+	draining     atomic.Bool
+	stopOnce     sync.Once
 }
 
 // NewServer creates a new HTTP server
@@ -31,6 +47,7 @@ func NewServer() *Server {
 		limiter:       ratelimit.NewLimiter(),
 		authenticator: auth.NewAuthenticator(),
 		config:        config.GetInstance().Get(),
+		errCh:         make(chan error, 1),
 	}
 
 	server.setupMiddleware()
@@ -40,40 +57,88 @@ func NewServer() *Server {
 }
 
 func (s *Server) setupMiddleware() {
+	// Request ID and access log first, so that rejected requests are
+	// identified and logged too
+	s.engine.Use(s.requestIDMiddleware())
+	s.engine.Use(s.accessLogMiddleware())
+
 	// CORS middleware
 	s.engine.Use(s.corsMiddleware())
 
 	// Security headers middleware
 	s.engine.Use(s.securityHeadersMiddleware())
 
+	// Request body size limit
+	s.engine.Use(s.bodyLimitMiddleware())
+
 	// Rate limiting middleware
 	s.engine.Use(s.rateLimitMiddleware())
 
 	// Authentication middleware
 	s.engine.Use(s.authMiddleware())
 
-	// Logging and recovery
-	s.engine.Use(gin.Logger())
-	s.engine.Use(gin.Recovery())
+	// Recovery
+	s.engine.Use(gin.CustomRecovery(func(c *gin.Context, err interface{}) {
+		slog.ErrorContext(c.Request.Context(), "Request handler panicked", "error", err)
+		abortWithError(c, http.StatusInternalServerError, "Internal server error")
+	}))
 }
 
 func (s *Server) setupRoutes() {
+	root := &s.engine.RouterGroup
+
 	// Health check endpoint
-	s.engine.GET("/health", s.healthHandler)
+	s.handle(root, http.MethodGet, "/health", "Check application health status", s.healthHandler)
 
 	// API routes
 	api := s.engine.Group("/api")
 	{
-		api.GET("/heartbeat", s.heartbeatHandler)
-		api.GET("/metrics", s.metricsHandler)
-		api.POST("/auth/token", s.createTokenHandler)
+		s.handle(api, http.MethodGet, "/heartbeat", "Get server heartbeat", s.heartbeatHandler)
+		s.handle(api, http.MethodGet, "/metrics", "Get application metrics", s.metricsHandler)
+		s.handle(api, http.MethodPost, "/auth/token", "Create authentication token", s.createTokenHandler)
+		s.handle(api, http.MethodGet, "/themes", "List available themes", s.themesHandler)
 	}
 
-	// Web interface routes
-	web := s.engine.Group("")
+	// Admin routes
+	admin := s.engine.Group(adminPrefix, s.adminMiddleware())
 	{
-		web.GET("/", s.indexHandler)
-		web.GET("/static/*filepath", s.staticFilesHandler)
+		s.handle(admin, http.MethodGet, "/config/history", "List configuration revisions", s.configHistoryHandler)
+		s.handle(admin, http.MethodPost, "/config/rollback", "Roll back to a configuration revision", s.configRollbackHandler)
+	}
+
+	// Web interface routes
+	s.handle(root, http.MethodGet, "/", "Web interface", s.indexHandler)
+	s.handle(root, http.MethodGet, "/static/*filepath", "Theme static files", s.staticFilesHandler)
+}
+
+// accessLogMiddleware logs every request once it has been handled
+func (s *Server) accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+		}
+		if token := c.GetString("token"); token != "" {
+			attrs = append(attrs, slog.String("token_id", auth.TokenID(token)))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
 	}
 }
 
@@ -97,7 +162,8 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 				c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 				c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, traceparent")
+				c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, traceparent")
 			}
 		}
 
@@ -131,6 +197,29 @@ func (s *Server) securityHeadersMiddleware() gin.HandlerFunc {
 		// Permissions Policy - restrict API access
 		c.Writer.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
 
+		// HSTS - keep clients on HTTPS once they reached it
+		if c.Request.TLS != nil {
+			c.Writer.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		}
+
+		c.Next()
+	}
+}
+
+// bodyLimitMiddleware rejects request bodies larger than the limit for the
+// route, see ServerConfig.BodyLimitFor
+func (s *Server) bodyLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := int64(config.GetInstance().GetServer().BodyLimitFor(c.Request.URL.Path))
+
+		if limit > 0 && c.Request.Body != nil {
+			if c.Request.ContentLength > limit {
+				abortWithError(c, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+
 		c.Next()
 	}
 }
@@ -146,10 +235,7 @@ func (s *Server) rateLimitMiddleware() gin.HandlerFunc {
 
 		identifier := c.ClientIP()
 		if !s.limiter.Allow(identifier) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded",
-			})
-			c.Abort()
+			abortWithError(c, http.StatusTooManyRequests, "Rate limit exceeded")
 			return
 		}
 
@@ -163,12 +249,7 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 		cfg := config.GetInstance().GetAPI()
 
 		// Skip auth for public endpoints
-		publicPaths := map[string]bool{
-			"/health":            true,
-			"/":                  true,
-		}
-
-		if publicPaths[c.Request.URL.Path] {
+		if isPublicPath(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
@@ -178,10 +259,7 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 			token := auth.ExtractToken(authHeader)
 
 			if !s.authenticator.ValidateToken(token) {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": "Unauthorized",
-				})
-				c.Abort()
+				abortWithError(c, http.StatusUnauthorized, "Unauthorized")
 				return
 			}
 
@@ -194,11 +272,27 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 
 // Handler functions
 func (s *Server) healthHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status": "healthy",
+	// Report draining with a 503 so that load balancers stop sending new
+	// requests while in-flight ones complete
+	status := http.StatusOK
+	if s.draining.Load() {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, gin.H{
+		"status": s.healthStatus(),
 	})
 }
 
+// healthStatus returns "draining" while the server shuts down and
+// "healthy" otherwise
+func (s *Server) healthStatus() string {
+	if s.draining.Load() {
+		return "draining"
+	}
+	return "healthy"
+}
+
 func (s *Server) heartbeatHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"timestamp": time.Now().Unix(),
@@ -219,8 +313,7 @@ func (s *Server) createTokenHandler(c *gin.Context) {
 		Duration int      `json:"duration"` // in seconds
 	}
 
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -229,23 +322,66 @@ func (s *Server) createTokenHandler(c *gin.Context) {
 		duration = 24 * time.Hour
 	}
 
+	// Only admins may hand out the admin scope. Without authentication
+	// nobody holds it, so tokens created then cannot become admin tokens
+	// once authentication is enabled.
+	for _, scope := range req.Scopes {
+		if scope == adminScope && !s.authenticator.HasScope(c.GetString("token"), adminScope) {
+			abortWithError(c, http.StatusForbidden, "Admin scope required to create admin tokens")
+			return
+		}
+	}
+
 	token := s.authenticator.CreateToken(req.Scopes, duration)
 	c.JSON(http.StatusOK, gin.H{
 		"token": token,
 	})
 }
 
-func (s *Server) indexHandler(c *gin.Context) {
-	cfg := config.GetInstance().GetServer()
+// bindJSON decodes the JSON request body into v. If that fails it responds
+// with 413 for bodies over the size limit, 408 for bodies not received
+// within server.read_timeout and 400 otherwise, and returns false.
+func bindJSON(c *gin.Context, v interface{}) bool {
+	err := c.ShouldBindJSON(v)
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &maxBytesErr):
+		abortWithError(c, http.StatusRequestEntityTooLarge, "Request body too large")
+	case errors.As(err, &netErr) && netErr.Timeout():
+		// The client is too slow to be worth waiting for again
+		c.Header("Connection", "close")
+		abortWithError(c, http.StatusRequestTimeout, "Request timeout")
+	default:
+		abortWithError(c, http.StatusBadRequest, "Invalid request")
+	}
+	return false
+}
 
-	// Render HTML from embedded files
-	theme := cfg.Theme
-	if theme == "" {
-		theme = "default"
+// abortWithError stops the handler chain and responds in the API's error
+// format, including the request ID so that clients can refer to the
+// request's log records
+func abortWithError(c *gin.Context, status int, message string) {
+	body := gin.H{
+		"error": message,
 	}
+	if id := c.GetString("request_id"); id != "" {
+		body["request_id"] = id
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+func (s *Server) indexHandler(c *gin.Context) {
+	cfg := config.GetInstance().GetServer()
+	theme := requestTheme(c)
 
-	html, err := getThemeFile(theme, "pages/index.html")
+	html, err := s.renderPage(theme, cfg.ThemesDir, "index", s.pageData(theme))
 	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to render page", "theme", theme, "page", "index", "error", err)
 		c.String(http.StatusInternalServerError, "Error loading page")
 		return
 	}
@@ -253,39 +389,108 @@ func (s *Server) indexHandler(c *gin.Context) {
 	c.Data(http.StatusOK, "text/html; charset=utf-8", html)
 }
 
-func (s *Server) staticFilesHandler(c *gin.Context) {
-	cfg := config.GetInstance().GetServer()
-	theme := cfg.Theme
-	if theme == "" {
-		theme = "default"
+// Run starts the HTTP server and blocks until it fails or is shut down
+// with Shutdown, in which case it returns nil. When tls_cert and tls_key
+// are set it serves HTTPS, plus a plain HTTP listener redirecting to it if
+// http_redirect_port is set. Changes to the listener settings while it
+// runs rebind the listeners, see rebind.
+func (s *Server) Run() error {
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return nil
 	}
-
-	filepath := c.Param("filepath")
-	if filepath == "" {
-		c.AbortWithStatus(http.StatusNotFound)
-		return
+	_, err := s.bind(config.GetInstance().GetServer())
+	s.mu.Unlock()
+	if err != nil {
+		return err
 	}
 
-	data, err := getThemeFile(theme, "static"+filepath)
-	if err != nil {
-		c.AbortWithStatus(http.StatusNotFound)
-		return
+	unsubscribe := config.GetInstance().Subscribe(s.rebind, "server")
+	defer unsubscribe()
+
+	if err := <-s.errCh; err != nil {
+		s.mu.Lock()
+		s.shuttingDown = true
+		current := s.current
+		s.mu.Unlock()
+
+		s.closeSockets()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		current.shutdown(ctx)
+		return err
 	}
+	return nil
+}
 
-	c.Data(http.StatusOK, getContentType(filepath), data)
+// newHTTPServer returns an http.Server listening on port with the
+// timeouts and header limit from cfg
+func newHTTPServer(cfg config.ServerConfig, port int, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Host, port),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout.Duration(),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration(),
+		WriteTimeout:      cfg.WriteTimeout.Duration(),
+		IdleTimeout:       cfg.IdleTimeout.Duration(),
+		MaxHeaderBytes:    int(cfg.MaxHeaderBytes),
+	}
 }
 
-// Run starts the HTTP server
-func (s *Server) Run() error {
-	cfg := config.GetInstance().GetServer()
-	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+// Shutdown gracefully stops the server. /health reports "draining" first,
+// for server.drain_delay so that load balancers stop routing new requests
+// here. The listeners are then closed and in-flight requests are given
+// until ctx expires to complete, after which remaining connections are
+// closed forcibly.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
+
+	delay := config.GetInstance().GetServer().DrainDelay.Duration()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	s.mu.Lock()
+	s.shuttingDown = true
+	current := s.current
+	s.mu.Unlock()
+
+	// Stop accepting connections, then wait for in-flight requests
+	s.closeSockets()
+	var err error
+	if current != nil {
+		err = current.shutdown(ctx)
+	}
+
+	// Let Run return
+	select {
+	case s.errCh <- nil:
+	default:
+	}
 
-	return s.engine.Run(addr)
+	s.Stop()
+	return err
 }
 
-// Stop gracefully stops the server
+// closeSockets closes the bound sockets. Servers using them keep the
+// connections they already accepted.
+func (s *Server) closeSockets() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sock := range s.sockets {
+		sock.Close()
+	}
+	s.sockets = nil
+}
+
+// Stop stops the server's background work. It is safe to call more than
+// once.
 func (s *Server) Stop() error {
-	s.limiter.Stop()
+	s.stopOnce.Do(s.limiter.Stop)
 	return nil
 }
 
@@ -294,20 +499,3 @@ var startTime = time.Now()
 func getUptime() int64 {
 	return int64(time.Since(startTime).Seconds())
 }
-
-func getContentType(filename string) string {
-	// Simplified content type detection
-	if strings.HasSuffix(filename, ".css") {
-		return "text/css"
-	}
-	if strings.HasSuffix(filename, ".js") {
-		return "application/javascript"
-	}
-	if strings.HasSuffix(filename, ".png") {
-		return "image/png"
-	}
-	if strings.HasSuffix(filename, ".jpg") || strings.HasSuffix(filename, ".jpeg") {
-		return "image/jpeg"
-	}
-	return "application/octet-stream"
-}
@@ -0,0 +1,349 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/pkg/auth"
+)
+
+// freePort returns a TCP port that is currently unused
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// startServer loads cfg and runs a server with extra test routes
+func startServer(t *testing.T, cfg string, routes func(*gin.Engine)) (*Server, string, <-chan error) {
+	t.Helper()
+
+	port := freePort(t)
+	if err := config.GetInstance().Load([]byte(fmt.Sprintf("server:\n  host: 127.0.0.1\n  port: %d\n%s", port, cfg))); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	server := NewServer()
+	routes(server.engine)
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Run() }()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		resp, err := http.Get(base + "/health")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return server, base, errCh
+}
+
+// serve passes req to server's handler without a listener
+func serve(server *Server, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	server.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+// newRequest returns a request for serve with the given body and headers,
+// either of which may be empty
+func newRequest(method, path, body string, header http.Header) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return req
+}
+
+// get serves a GET request for path with the given headers, which may be nil
+func get(server *Server, path string, header http.Header) *httptest.ResponseRecorder {
+	return serve(server, newRequest(http.MethodGet, path, "", header))
+}
+
+// writeFile writes content to the slash-separated name below dir, creating
+// directories as needed, and returns the file's path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	server, base, errCh := startServer(t, "  drain_delay: 200ms\n", func(engine *gin.Engine) {
+		engine.GET("/slow", func(c *gin.Context) {
+			close(started)
+			time.Sleep(300 * time.Millisecond)
+			c.String(http.StatusOK, "done")
+		})
+	})
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- server.Shutdown(context.Background()) }()
+
+	// While draining, health checks fail but requests are still served
+	time.Sleep(50 * time.Millisecond)
+	resp, err := http.Get(base + "/health")
+	if err != nil {
+		t.Fatalf("health check during drain failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("health status = %d, want 503 while draining", resp.StatusCode)
+	}
+
+	if body := <-slow; body != "done" {
+		t.Fatalf("in-flight request got %q, want it to complete", body)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Run returned %v, want nil after Shutdown", err)
+	}
+}
+
+func TestShutdownForcesCloseAfterTimeout(t *testing.T) {
+	started := make(chan struct{})
+	server, base, errCh := startServer(t, "", func(engine *gin.Engine) {
+		engine.GET("/stuck", func(c *gin.Context) {
+			close(started)
+			time.Sleep(2 * time.Second)
+		})
+	})
+	go http.Get(base + "/stuck")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err == nil {
+		t.Fatal("Shutdown should report connections that did not drain")
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Run returned %v, want nil after Shutdown", err)
+	}
+}
+
+func TestBodyLimitsAndTimeouts(t *testing.T) {
+	server, base, _ := startServer(t, "  read_timeout: 300ms\n", func(*gin.Engine) {})
+	defer server.Shutdown(context.Background())
+
+	// The token endpoint has a small default limit
+	body := `{"scopes": ["` + strings.Repeat("x", 70*1024) + `"]}`
+	for _, contentLength := range []int64{int64(len(body)), -1} {
+		req, _ := http.NewRequest(http.MethodPost, base+"/api/auth/token", strings.NewReader(body))
+		req.ContentLength = contentLength
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge || !strings.Contains(string(got), `"error"`) {
+			t.Fatalf("content length %d: got %d %s, want 413 with an error", contentLength, resp.StatusCode, got)
+		}
+	}
+
+	// A body that arrives too slowly times out
+	conn, err := net.Dial("tcp", strings.TrimPrefix(base, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "POST /api/auth/token HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("reading response failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Fatalf("slow body got %d, want 408", resp.StatusCode)
+	}
+}
+
+func TestRebindOnConfigChange(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server, base, errCh := startServer(t, "", func(engine *gin.Engine) {
+		engine.GET("/slow", func(c *gin.Context) {
+			started <- struct{}{}
+			time.Sleep(200 * time.Millisecond)
+			c.String(http.StatusOK, "done")
+		})
+	})
+	defer server.Shutdown(context.Background())
+
+	get := func(url string) (string, error) {
+		resp, err := http.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+	load := func(port int, extra string) {
+		t.Helper()
+		if err := config.GetInstance().Load([]byte(fmt.Sprintf("server:\n  host: 127.0.0.1\n  port: %d\n%s", port, extra))); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+	}
+
+	// Moving to a new port keeps in-flight requests on the old one
+	slow := make(chan string, 1)
+	go func() {
+		body, err := get(base + "/slow")
+		if err != nil {
+			body = err.Error()
+		}
+		slow <- body
+	}()
+	<-started
+
+	port := freePort(t)
+	load(port, "")
+	moved := fmt.Sprintf("http://127.0.0.1:%d", port)
+	if _, err := get(moved + "/health"); err != nil {
+		t.Fatalf("new listener not serving: %v", err)
+	}
+	if body := <-slow; body != "done" {
+		t.Fatalf("in-flight request got %q, want it to complete", body)
+	}
+	if _, err := net.Dial("tcp", strings.TrimPrefix(base, "http://")); err == nil {
+		t.Fatal("old listener still accepts connections")
+	}
+
+	// Settings needing new servers on the same port reuse its socket
+	load(port, "  read_timeout: 5s\n")
+	if _, err := get(moved + "/health"); err != nil {
+		t.Fatalf("listener not serving after a timeout change: %v", err)
+	}
+
+	// A port that cannot be bound leaves the working listener in place
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	load(busy.Addr().(*net.TCPAddr).Port, "")
+	if _, err := get(moved + "/health"); err != nil {
+		t.Fatalf("listener lost after a failed rebind: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("Run returned %v while rebinding", err)
+	default:
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAccessLog(t *testing.T) {
+	var logs syncBuffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	server, base, _ := startServer(t, "api:\n  auth_enabled: true\n", func(*gin.Engine) {})
+	defer server.Shutdown(context.Background())
+	token := server.authenticator.CreateToken([]string{"read"}, time.Hour)
+
+	for _, header := range []string{"Bearer " + token, ""} {
+		req, _ := http.NewRequest(http.MethodGet, base+"/api/heartbeat", nil)
+		req.Header.Set("Authorization", header)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if record["msg"] == "request" && record["path"] == "/api/heartbeat" {
+			records = append(records, record)
+		}
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d access log records, want 2:\n%s", len(records), logs.String())
+	}
+
+	authorized, rejected := records[0], records[1]
+	if authorized["method"] != "GET" || authorized["status"] != float64(200) || authorized["client_ip"] != "127.0.0.1" {
+		t.Fatalf("unexpected record %v", authorized)
+	}
+	if authorized["token_id"] != auth.TokenID(token) {
+		t.Fatalf("token_id = %v, want %s", authorized["token_id"], auth.TokenID(token))
+	}
+	if rejected["status"] != float64(401) || rejected["token_id"] != nil {
+		t.Fatalf("unexpected record for rejected request %v", rejected)
+	}
+	if strings.Contains(logs.String(), token) {
+		t.Fatal("access log reveals the token")
+	}
+}
@@ -0,0 +1,288 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/internal/themes"
+	"github.com/yourusername/stroganoff/pkg/version"
+)
+
+const (
+	// fingerprintLength is the number of hex digits of the content hash in
+	// fingerprinted asset names, e.g. style.3f9a1c0b7e2d.css
+	fingerprintLength = 12
+
+	// immutableCacheControl is sent for fingerprinted assets, whose content
+	// never changes under the same URL
+	immutableCacheControl = "public, max-age=31536000, immutable"
+
+	// revalidateCacheControl is sent for other assets, which clients may
+	// keep but must revalidate with their ETag before use
+	revalidateCacheControl = "no-cache"
+)
+
+// contentTypes maps file extensions to content types. It takes precedence
+// over the system's MIME database, which differs between hosts and often
+// lacks web fonts.
+var contentTypes = map[string]string{
+	".css":         "text/css; charset=utf-8",
+	".js":          "text/javascript; charset=utf-8",
+	".mjs":         "text/javascript; charset=utf-8",
+	".json":        "application/json",
+	".map":         "application/json",
+	".webmanifest": "application/manifest+json",
+	".html":        "text/html; charset=utf-8",
+	".txt":         "text/plain; charset=utf-8",
+	".xml":         "application/xml",
+	".svg":         "image/svg+xml",
+	".png":         "image/png",
+	".jpg":         "image/jpeg",
+	".jpeg":        "image/jpeg",
+	".gif":         "image/gif",
+	".webp":        "image/webp",
+	".avif":        "image/avif",
+	".ico":         "image/x-icon",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".otf":         "font/otf",
+	".wasm":        "application/wasm",
+}
+
+// precompressed lists the content encodings of precompressed asset
+// variants in order of preference, with the suffix of their files
+var precompressed = []struct {
+	encoding string
+	suffix   string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// variantHashSuffix is the suffix of the file next to a static file that
+// holds the hex SHA-256 of the content its precompressed variants were
+// made from, as written by compress-assets
+const variantHashSuffix = ".sha256"
+
+// assetEpoch stands in for the modification time of embedded files, which
+// have none: the build date, or the start of the process
+var assetEpoch = func() time.Time {
+	if t, err := time.Parse(time.RFC3339, version.Get().BuildDate); err == nil {
+		return t
+	}
+	return startTime
+}()
+
+// asset is a static file as read from a theme
+type asset struct {
+	data    []byte
+	modTime time.Time // As reported by the file system; zero for embedded files
+	hash    string    // Hex SHA-256 of data
+}
+
+// assetCache keeps static files read from themes along with their hashes.
+// Entries are reused as long as the file's size and modification time
+// match, so edits in the themes directory are picked up.
+type assetCache struct {
+	mu      sync.Mutex
+	entries map[string]*asset
+}
+
+// load reads name from fsys. key identifies fsys, e.g. by theme.
+func (ac *assetCache) load(fsys fs.FS, key, name string) (*asset, error) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	key += "\x00" + name
+	ac.mu.Lock()
+	a := ac.entries[key]
+	ac.mu.Unlock()
+	if a != nil && a.modTime.Equal(info.ModTime()) && int64(len(a.data)) == info.Size() {
+		return a, nil
+	}
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	a = &asset{data: data, modTime: info.ModTime(), hash: hex.EncodeToString(sum[:])}
+
+	ac.mu.Lock()
+	if ac.entries == nil {
+		ac.entries = make(map[string]*asset)
+	}
+	ac.entries[key] = a
+	ac.mu.Unlock()
+	return a, nil
+}
+
+// assetURL returns a function for templates mapping a path below a
+// theme's static directory to its fingerprinted URL, or to its plain URL
+// if the file cannot be read
+func (s *Server) assetURL(fsys fs.FS, key string) func(string) string {
+	return func(name string) string {
+		name = strings.TrimPrefix(name, "/")
+		if !fs.ValidPath(name) {
+			return "/static/" + name
+		}
+		a, err := s.assets.load(fsys, key, "static/"+name)
+		if err != nil {
+			return "/static/" + name
+		}
+		return "/static/" + fingerprinted(name, a.hash[:fingerprintLength])
+	}
+}
+
+// fingerprinted inserts a fingerprint before the extension of name. Names
+// without an extension are returned unchanged.
+func fingerprinted(name, fingerprint string) string {
+	ext := path.Ext(name)
+	if ext == "" || ext == path.Base(name) {
+		return name
+	}
+	return strings.TrimSuffix(name, ext) + "." + fingerprint + ext
+}
+
+// splitFingerprint undoes fingerprinted, returning name unchanged and no
+// fingerprint if it carries none
+func splitFingerprint(name string) (string, string) {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	i := strings.LastIndexByte(stem, '.')
+	if ext == "" || i < 0 || strings.Contains(stem[i:], "/") || !isLowerHex(stem[i+1:], fingerprintLength) {
+		return name, ""
+	}
+	return stem[:i] + ext, stem[i+1:]
+}
+
+// contentType returns the content type of a file from its extension,
+// sniffing data if the extension is unknown
+func contentType(name string, data []byte) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ctype, ok := contentTypes[ext]; ok {
+		return ctype
+	}
+	if ctype := mime.TypeByExtension(ext); ctype != "" {
+		return ctype
+	}
+	return http.DetectContentType(data)
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header accepts the
+// given content encoding with a non-zero quality
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			quality, err := strconv.ParseFloat(q, 64)
+			return err == nil && quality > 0
+		}
+		return true
+	}
+	return false
+}
+
+// staticFilesHandler serves the files in the static directory of the
+// request's theme. Conditional requests are answered from the ETag and
+// Last-Modified headers. Fingerprinted names, as produced by the asset
+// template function, serve the file without the fingerprint and may be
+// cached forever if the fingerprint matches its content. A precompressed
+// .br or .gz variant next to the file is served to clients accepting it
+// if it is current, see variantsCurrent.
+func (s *Server) staticFilesHandler(c *gin.Context) {
+	cfg := config.GetInstance().GetServer()
+	theme := requestTheme(c)
+
+	name := strings.TrimPrefix(c.Param("filepath"), "/")
+	if name == "" || !fs.ValidPath(name) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	fsys, err := themes.FS(theme, cfg.ThemesDir)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	key := theme + "\x00" + cfg.ThemesDir
+
+	// A file whose name merely looks fingerprinted is served as is
+	plain, fingerprint := splitFingerprint(name)
+	file, err := s.assets.load(fsys, key, "static/"+plain)
+	if err != nil && fingerprint != "" {
+		plain, fingerprint = name, ""
+		file, err = s.assets.load(fsys, key, "static/"+plain)
+	}
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if fingerprint != "" && fingerprint == file.hash[:fingerprintLength] {
+		header.Set("Cache-Control", immutableCacheControl)
+	} else {
+		header.Set("Cache-Control", revalidateCacheControl)
+	}
+	header.Set("Content-Type", contentType(plain, file.data))
+
+	served := file
+	current := s.variantsCurrent(fsys, key, "static/"+plain, file)
+	for _, variant := range precompressed {
+		if !acceptsEncoding(c.GetHeader("Accept-Encoding"), variant.encoding) {
+			continue
+		}
+		compressed, err := s.assets.load(fsys, key, "static/"+plain+variant.suffix)
+		if err != nil || !current(compressed) {
+			continue
+		}
+		header.Set("Content-Encoding", variant.encoding)
+		served = compressed
+		break
+	}
+
+	modTime := file.modTime
+	if modTime.IsZero() {
+		modTime = assetEpoch
+	}
+	header.Set("ETag", `"`+served.hash[:32]+`"`)
+	http.ServeContent(c.Writer, c.Request, "", modTime, bytes.NewReader(served.data))
+}
+
+// variantsCurrent returns a function reporting whether a precompressed
+// variant of file, read from name in fsys, was made from its current
+// content. If compress-assets recorded the hash of the file it was run on,
+// that hash must match. Otherwise variants are only trusted if they are at
+// least as new as the file, which rules out embedded files: they have no
+// modification time to compare.
+func (s *Server) variantsCurrent(fsys fs.FS, key, name string, file *asset) func(*asset) bool {
+	if recorded, err := s.assets.load(fsys, key, name+variantHashSuffix); err == nil {
+		matches := strings.TrimSpace(string(recorded.data)) == file.hash
+		return func(*asset) bool { return matches }
+	}
+	return func(variant *asset) bool {
+		return !file.modTime.IsZero() && !variant.modTime.Before(file.modTime)
+	}
+}
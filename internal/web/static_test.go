@@ -0,0 +1,215 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/yourusername/stroganoff/internal/config"
+)
+
+// hashOf returns the hex SHA-256 of s, as recorded by compress-assets
+func hashOf(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestStaticFiles(t *testing.T) {
+	dir := t.TempDir()
+	css := strings.Repeat("body { color: teal; }\n", 50)
+	gzipped := func(s string) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+		return buf.String()
+	}
+
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	writeAsset := func(name, content string, modTime time.Time) {
+		t.Helper()
+		path := writeFile(t, dir, "assets/"+name, content)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeAsset("theme.yaml", "name: assets\nparent: default\n", modTime)
+	writeAsset("layouts/base.html", `{{define "layout"}}<link href="{{asset "css/site.css"}}"><img src="{{asset "img/missing.png"}}">{{end}}`, modTime)
+	writeAsset("static/css/site.css", css, modTime)
+	writeAsset("static/css/site.css.gz", gzipped(css), modTime)
+	writeAsset("static/css/site.css.br", "fake brotli", modTime)
+	writeAsset("static/js/old.js", "console.log(1)", modTime)
+	writeAsset("static/js/old.js.gz", gzipped("stale"), modTime.Add(-time.Hour))
+	writeAsset("static/js/edited.js", "console.log(2)", modTime)
+	writeAsset("static/js/edited.js.gz", gzipped("stale"), modTime)
+	writeAsset("static/js/edited.js.sha256", hashOf("console.log(1)")+"\n", modTime)
+	writeAsset("static/js/copied.js", "console.log(3)", modTime)
+	writeAsset("static/js/copied.js.gz", gzipped("console.log(3)"), modTime.Add(-time.Hour))
+	writeAsset("static/js/copied.js.sha256", hashOf("console.log(3)")+"\n", modTime)
+	writeAsset("static/img/logo.svg", `<svg xmlns="http://www.w3.org/2000/svg"></svg>`, modTime)
+	writeAsset("static/fonts/body.woff2", "wOF2", modTime)
+	writeAsset("static/favicon.ico", "\x00\x00\x01\x00", modTime)
+	writeAsset("static/data.json", `{}`, modTime)
+	writeAsset("static/blob", "%PDF-1.4", modTime)
+
+	if err := config.GetInstance().Load([]byte("server:\n  theme: assets\n  themes_dir: " + dir + "\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	server := NewServer()
+	defer server.Stop()
+
+	t.Run("content types", func(t *testing.T) {
+		for path, want := range map[string]string{
+			"/static/css/site.css":          "text/css; charset=utf-8",
+			"/static/img/logo.svg":          "image/svg+xml",
+			"/static/fonts/body.woff2":      "font/woff2",
+			"/static/favicon.ico":           "image/x-icon",
+			"/static/data.json":             "application/json",
+			"/static/blob":                  "application/pdf",
+			"/static/js/app.js":             "text/javascript; charset=utf-8",
+			"/static/css/theme-default.css": "text/css; charset=utf-8",
+		} {
+			rec := get(server, path, nil)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != want {
+				t.Errorf("GET %s = %d %q, want 200 %q", path, rec.Code, rec.Header().Get("Content-Type"), want)
+			}
+		}
+	})
+
+	t.Run("conditional requests", func(t *testing.T) {
+		rec := get(server, "/static/css/site.css", nil)
+		etag := rec.Header().Get("ETag")
+		if etag == "" || rec.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+			t.Fatalf("GET site.css: ETag %q, Last-Modified %q", etag, rec.Header().Get("Last-Modified"))
+		}
+		if got := rec.Header().Get("Cache-Control"); got != revalidateCacheControl {
+			t.Errorf("Cache-Control = %q, want %q", got, revalidateCacheControl)
+		}
+		if rec := get(server, "/static/css/site.css", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("GET with If-None-Match = %d, want 304", rec.Code)
+		}
+		if rec := get(server, "/static/css/site.css", http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}}); rec.Code != http.StatusNotModified {
+			t.Errorf("GET with If-Modified-Since = %d, want 304", rec.Code)
+		}
+		if rec := get(server, "/static/css/site.css", http.Header{"If-None-Match": {`"other"`}}); rec.Code != http.StatusOK {
+			t.Errorf("GET with a different ETag = %d, want 200", rec.Code)
+		}
+
+		// Embedded files have no modification time of their own
+		if rec := get(server, "/static/js/app.js", nil); rec.Header().Get("Last-Modified") == "" {
+			t.Error("GET embedded app.js has no Last-Modified")
+		}
+	})
+
+	t.Run("fingerprints", func(t *testing.T) {
+		page := get(server, "/", nil)
+		match := regexp.MustCompile(`href="(/static/css/site\.[0-9a-f]{12}\.css)"`).FindStringSubmatch(page.Body.String())
+		if match == nil {
+			t.Fatalf("page does not link a fingerprinted site.css: %s", page.Body.String())
+		}
+		if !strings.Contains(page.Body.String(), `src="/static/img/missing.png"`) {
+			t.Errorf("missing asset not linked by its plain URL: %s", page.Body.String())
+		}
+
+		rec := get(server, match[1], nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != css || rec.Header().Get("Cache-Control") != immutableCacheControl {
+			t.Errorf("GET %s = %d, Cache-Control %q, want the file with %q", match[1], rec.Code, rec.Header().Get("Cache-Control"), immutableCacheControl)
+		}
+
+		// A stale fingerprint still serves the file, but not forever
+		rec = get(server, "/static/css/site.000000000000.css", nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != css || rec.Header().Get("Cache-Control") != revalidateCacheControl {
+			t.Errorf("GET stale fingerprint = %d, Cache-Control %q, want the file with %q", rec.Code, rec.Header().Get("Cache-Control"), revalidateCacheControl)
+		}
+	})
+
+	t.Run("precompressed", func(t *testing.T) {
+		tests := []struct {
+			path, accept, encoding, body string
+		}{
+			{"/static/css/site.css", "gzip, deflate, br", "br", "fake brotli"},
+			{"/static/css/site.css", "gzip, br;q=0", "gzip", gzipped(css)},
+			{"/static/css/site.css", "", "", css},
+			{"/static/js/old.js", "gzip", "", "console.log(1)"},                 // Variant older than the file
+			{"/static/js/edited.js", "gzip", "", "console.log(2)"},              // Recorded hash of another content
+			{"/static/js/copied.js", "gzip", "gzip", gzipped("console.log(3)")}, // Recorded hash matches
+		}
+		for _, test := range tests {
+			rec := get(server, test.path, http.Header{"Accept-Encoding": {test.accept}})
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != test.encoding || rec.Body.String() != test.body {
+				t.Errorf("GET %s with Accept-Encoding %q = %d, Content-Encoding %q, want %q", test.path, test.accept, rec.Code, rec.Header().Get("Content-Encoding"), test.encoding)
+			}
+			if vary := strings.Join(rec.Header().Values("Vary"), ", "); !strings.Contains(vary, "Accept-Encoding") {
+				t.Errorf("GET %s: Vary %q lacks Accept-Encoding", test.path, vary)
+			}
+		}
+
+		identity := get(server, "/static/css/site.css", nil).Header().Get("ETag")
+		compressed := get(server, "/static/css/site.css", http.Header{"Accept-Encoding": {"gzip"}}).Header().Get("ETag")
+		if identity == compressed {
+			t.Error("compressed and identity responses share an ETag")
+		}
+	})
+}
+
+func TestVariantsCurrent(t *testing.T) {
+	// Embedded files have no modification time to compare variants with
+	fsys := fstest.MapFS{
+		"static/app.js":           {Data: []byte("new")},
+		"static/app.js.gz":        {Data: []byte("old")},
+		"static/style.css":        {Data: []byte("new")},
+		"static/style.css.gz":     {Data: []byte("new")},
+		"static/style.css.sha256": {Data: []byte(hashOf("new") + "\n")},
+		"static/theme.css":        {Data: []byte("new")},
+		"static/theme.css.gz":     {Data: []byte("old")},
+		"static/theme.css.sha256": {Data: []byte(hashOf("old") + "\n")},
+	}
+	s := &Server{}
+	for name, want := range map[string]bool{
+		"static/app.js":    false,
+		"static/style.css": true,
+		"static/theme.css": false,
+	} {
+		file, err := s.assets.load(fsys, "embedded", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		variant, err := s.assets.load(fsys, "embedded", name+".gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.variantsCurrent(fsys, "embedded", name, file)(variant); got != want {
+			t.Errorf("variant of %s current = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSplitFingerprint(t *testing.T) {
+	tests := []struct {
+		name, plain, fingerprint string
+	}{
+		{"css/style.3f9a1c0b7e2d.css", "css/style.css", "3f9a1c0b7e2d"},
+		{"css/style.css", "css/style.css", ""},
+		{"css/style.min.css", "css/style.min.css", ""},
+		{"css/style.3F9A1C0B7E2D.css", "css/style.3F9A1C0B7E2D.css", ""},
+		{"v.3f9a1c0b7e2d/style.css", "v.3f9a1c0b7e2d/style.css", ""},
+		{"LICENSE", "LICENSE", ""},
+	}
+	for _, test := range tests {
+		plain, fingerprint := splitFingerprint(test.name)
+		if plain != test.plain || fingerprint != test.fingerprint {
+			t.Errorf("splitFingerprint(%q) = %q, %q, want %q, %q", test.name, plain, fingerprint, test.plain, test.fingerprint)
+		}
+		if test.fingerprint != "" && fingerprinted(plain, fingerprint) != test.name {
+			t.Errorf("fingerprinted(%q, %q) = %q, want %q", plain, fingerprint, fingerprinted(plain, fingerprint), test.name)
+		}
+	}
+}
@@ -1,124 +1,72 @@
 package web
 
 import (
-	"embed"
-	"fmt"
-	"io/fs"
-	"path/filepath"
-)
-
-// NOTE: Embed directive commented out - can be enabled when building from project root
-// //go:embed web/themes
-// var themeFS embed.FS
-
-var themeFS embed.FS
-
-// getThemeFile retrieves a file from the embedded theme filesystem
-func getThemeFile(theme, filename string) ([]byte, error) {
-	// Validate theme name to prevent directory traversal
-	if theme == "" || theme == "." || filepath.IsAbs(theme) {
-		return nil, fmt.Errorf("invalid theme")
-	}
+	"log/slog"
+	"net/http"
+	"time"
 
-	// For now, return a default page since embed is relative to build directory
-	defaultHTML := []byte(`<!DOCTYPE html>
-<html>
-<head>
-  <title>stroganoff</title>
-  <style>
-    body { font-family: Arial, sans-serif; margin: 40px; }
-    .container { max-width: 1200px; margin: 0 auto; }
-    .navbar { background: #f0f0f0; padding: 1rem; margin-bottom: 2rem; border-radius: 4px; }
-    .navbar h1 { margin: 0; color: #0066cc; }
-    .hero { text-align: center; margin: 3rem 0; }
-    .hero h2 { font-size: 2.5rem; color: #0066cc; }
-    .features { display: grid; grid-template-columns: repeat(auto-fit, minmax(300px, 1fr)); gap: 1.5rem; margin: 2rem 0; }
-    .feature { padding: 1rem; background: #f9f9f9; border-radius: 4px; border-left: 4px solid #0066cc; }
-    .endpoint { margin: 1.5rem 0; padding: 1rem; background: #f0f0f0; border-radius: 4px; }
-    .endpoint code { display: block; background: #e0e0e0; padding: 0.5rem; margin-bottom: 0.5rem; border-radius: 3px; }
-    footer { margin-top: 3rem; padding-top: 1rem; border-top: 1px solid #ddd; text-align: center; color: #666; }
-  </style>
-</head>
-<body>
-  <div class="container">
-    <nav class="navbar">
-      <h1>stroganoff</h1>
-    </nav>
-
-    <main>
-      <section class="hero">
-        <h2>Welcome to stroganoff</h2>
-        <p>A professional Go CLI application with advanced features</p>
-      </section>
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/internal/themes"
+)
 
-      <section class="features">
-        <div class="feature">✓ Multi-OS/Architecture Support</div>
-        <div class="feature">✓ Service Installation & Management</div>
-        <div class="feature">✓ Configuration Management with Hot-Reload</div>
-        <div class="feature">✓ API Authentication & Rate Limiting</div>
-        <div class="feature">✓ Theme Support</div>
-        <div class="feature">✓ Monitoring & Health Checks</div>
-        <div class="feature">✓ Automatic Updates from Github</div>
-      </section>
+const (
+	// themeCookie remembers the theme a visitor selected
+	themeCookie = "theme"
 
-      <section>
-        <h3>API Endpoints</h3>
-        <div class="endpoint">
-          <code>GET /health</code>
-          <p>Check application health status</p>
-        </div>
-        <div class="endpoint">
-          <code>GET /api/heartbeat</code>
-          <p>Get server heartbeat</p>
-        </div>
-        <div class="endpoint">
-          <code>GET /api/metrics</code>
-          <p>Get application metrics (requires auth)</p>
-        </div>
-        <div class="endpoint">
-          <code>POST /api/auth/token</code>
-          <p>Create authentication token</p>
-        </div>
-      </section>
-    </main>
+	// themeCookieMaxAge is how long the selection is remembered
+	themeCookieMaxAge = 365 * 24 * time.Hour
+)
 
-    <footer>
-      <p>&copy; 2024 stroganoff. All rights reserved.</p>
-    </footer>
-  </div>
-</body>
-</html>`)
+func init() {
+	config.ThemeExists = themes.Exists
+}
 
-	return defaultHTML, nil
+// GetAvailableThemes returns the names of the themes that can be selected
+// with server.theme
+func GetAvailableThemes() ([]string, error) {
+	return themes.Names(config.GetInstance().GetServer().ThemesDir)
 }
 
-// isPathSafe checks if a path is safe from directory traversal attacks
-func isPathSafe(path, baseDir string) bool {
-	absPath := filepath.Join(baseDir, path)
-	baseDirAbs := filepath.Join(baseDir)
+// requestTheme returns the theme to render a request with. A theme
+// selected with the ?theme= query parameter wins and is remembered in the
+// theme cookie; otherwise the cookie's theme is used, and without either
+// the configured server.theme. Unknown themes are ignored.
+func requestTheme(c *gin.Context) string {
+	cfg := config.GetInstance().GetServer()
 
-	// Check if the absolute path starts with the base directory
-	if len(absPath) < len(baseDirAbs) {
-		return false
-	}
+	// Responses differ by cookie, so shared caches must not mix them up
+	c.Header("Vary", "Cookie")
 
-	return absPath[:len(baseDirAbs)] == baseDirAbs
+	if theme := c.Query("theme"); theme != "" && themes.Exists(theme, cfg.ThemesDir) {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(themeCookie, theme, int(themeCookieMaxAge.Seconds()), "/", "", c.Request.TLS != nil, true)
+		return theme
+	}
+	if theme, err := c.Cookie(themeCookie); err == nil && themes.Exists(theme, cfg.ThemesDir) {
+		return theme
+	}
+	if cfg.Theme != "" {
+		return cfg.Theme
+	}
+	return "default"
 }
 
-// GetAvailableThemes returns a list of available themes
-func GetAvailableThemes() ([]string, error) {
-	var themes []string
+// themesHandler lists the available themes along with the configured
+// default and the theme selected for the request
+func (s *Server) themesHandler(c *gin.Context) {
+	cfg := config.GetInstance().GetServer()
 
-	themeDir, err := fs.ReadDir(themeFS, "web/themes")
+	list, err := themes.List(cfg.ThemesDir)
 	if err != nil {
-		return nil, err
-	}
-
-	for _, entry := range themeDir {
-		if entry.IsDir() {
-			themes = append(themes, entry.Name())
-		}
+		slog.ErrorContext(c.Request.Context(), "Failed to list themes", "error", err)
+		abortWithError(c, http.StatusInternalServerError, "Failed to list themes")
+		return
 	}
 
-	return themes, nil
+	c.JSON(http.StatusOK, gin.H{
+		"themes":  list,
+		"default": cfg.Theme,
+		"current": requestTheme(c),
+	})
 }
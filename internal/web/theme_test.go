@@ -0,0 +1,159 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/stroganoff/internal/config"
+	"github.com/yourusername/stroganoff/internal/themes"
+)
+
+func TestThemes(t *testing.T) {
+	dir := t.TempDir()
+	// An override of a single file of a built-in theme and a new theme
+	writeFile(t, dir, "dark/static/css/style.css", "body { color: red; }")
+	writeFile(t, dir, "solar/pages/index.html", "<h1>solar</h1>")
+
+	t.Run("embedded", func(t *testing.T) {
+		if err := config.GetInstance().Load([]byte("server:\n  theme: dark\n")); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		server := NewServer()
+		defer server.Stop()
+
+		if rec := get(server, "/", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/static/css/theme-dark.") {
+			t.Errorf("GET / = %d %q, want the embedded dark index page", rec.Code, rec.Body.String())
+		}
+		if rec := get(server, "/static/css/theme-dark.css", nil); rec.Code != http.StatusOK || rec.Body.String() == "" {
+			t.Errorf("GET theme-dark.css = %d, want 200 with content", rec.Code)
+		}
+		for _, path := range []string{"/static/css/missing.css", "/static/../pages/index.html", "/static/"} {
+			if rec := get(server, path, nil); rec.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %d, want 404", path, rec.Code)
+			}
+		}
+
+		names, err := GetAvailableThemes()
+		if err != nil || !reflect.DeepEqual(names, []string{"dark", "default"}) {
+			t.Errorf("GetAvailableThemes() = %v, %v, want [dark default]", names, err)
+		}
+	})
+
+	t.Run("themes dir", func(t *testing.T) {
+		if err := config.GetInstance().Load([]byte("server:\n  theme: dark\n  themes_dir: " + dir + "\n")); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		server := NewServer()
+		defer server.Stop()
+
+		if rec := get(server, "/static/css/style.css", nil); rec.Code != http.StatusOK || rec.Body.String() != "body { color: red; }" {
+			t.Errorf("GET style.css = %d %q, want the overriding file", rec.Code, rec.Body.String())
+		}
+		// Files not overridden still come from the built-in theme
+		if rec := get(server, "/", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/static/css/theme-dark.") {
+			t.Errorf("GET / = %d, want the embedded dark index page", rec.Code)
+		}
+
+		names, err := GetAvailableThemes()
+		if err != nil || !reflect.DeepEqual(names, []string{"dark", "default", "solar"}) {
+			t.Errorf("GetAvailableThemes() = %v, %v, want [dark default solar]", names, err)
+		}
+
+		if err := config.GetInstance().Load([]byte("server:\n  theme: solar\n  themes_dir: " + dir + "\n")); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if rec := get(server, "/", nil); rec.Code != http.StatusOK || rec.Body.String() != "<h1>solar</h1>" {
+			t.Errorf("GET / = %d %q, want the solar index page", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		if err := config.GetInstance().Load([]byte("server:\n  theme: solar\n")); err == nil {
+			t.Error("Load accepted a theme only found in an unconfigured themes dir")
+		}
+		if err := config.GetInstance().Load([]byte("server:\n  themes_dir: " + filepath.Join(dir, "missing") + "\n")); err == nil {
+			t.Error("Load accepted a missing themes dir")
+		}
+	})
+}
+
+func TestThemeSelection(t *testing.T) {
+	if err := config.GetInstance().Load([]byte("server:\n  theme: default\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	server := NewServer()
+	defer server.Stop()
+
+	withCookie := func(theme string) http.Header {
+		if theme == "" {
+			return nil
+		}
+		return http.Header{"Cookie": {themeCookie + "=" + theme}}
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		cookie    string
+		want      string // Theme stylesheet linked from the page
+		setCookie string
+	}{
+		{"configured", "/", "", "/static/css/theme-default.", ""},
+		{"query", "/?theme=dark", "", "/static/css/theme-dark.", "dark"},
+		{"cookie", "/", "dark", "/static/css/theme-dark.", ""},
+		{"query over cookie", "/?theme=default", "dark", "/static/css/theme-default.", "default"},
+		{"unknown query", "/?theme=nope", "", "/static/css/theme-default.", ""},
+		{"unknown cookie", "/", "../dark", "/static/css/theme-default.", ""},
+	}
+
+	for _, test := range tests {
+		rec := get(server, test.path, withCookie(test.cookie))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), test.want) {
+			t.Errorf("%s: GET %s = %d, want a page linking %s", test.name, test.path, rec.Code, test.want)
+		}
+
+		var got string
+		for _, cookie := range rec.Result().Cookies() {
+			if cookie.Name == themeCookie {
+				got = cookie.Value
+			}
+		}
+		if got != test.setCookie {
+			t.Errorf("%s: theme cookie set to %q, want %q", test.name, got, test.setCookie)
+		}
+	}
+
+	// Static files follow the selected theme
+	if rec := get(server, "/static/css/theme-dark.css", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET theme-dark.css of the default theme = %d, want 404", rec.Code)
+	}
+	if rec := get(server, "/static/css/theme-dark.css", withCookie("dark")); rec.Code != http.StatusOK {
+		t.Errorf("GET theme-dark.css with dark cookie = %d, want 200", rec.Code)
+	}
+
+	// The switcher offers every theme
+	if body := get(server, "/", nil).Body.String(); !strings.Contains(body, `<option value="dark">dark</option>`) || !strings.Contains(body, `<option value="default" selected>default</option>`) {
+		t.Errorf("index page lacks the theme switcher: %s", body)
+	}
+
+	rec := get(server, "/api/themes", withCookie("dark"))
+	var resp struct {
+		Themes  []themes.Info `json:"themes"`
+		Default string        `json:"default"`
+		Current string        `json:"current"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("GET /api/themes = %d %s: %v", rec.Code, rec.Body.String(), err)
+	}
+	want := []themes.Info{
+		{Name: "dark", Version: "1.0.0", Author: "Stroganoff", Description: "Dark theme with light blue accents", Builtin: true},
+		{Name: "default", Version: "1.0.0", Author: "Stroganoff", Description: "Light theme with blue accents", Builtin: true},
+	}
+	if !reflect.DeepEqual(resp.Themes, want) || resp.Default != "default" || resp.Current != "dark" {
+		t.Errorf("GET /api/themes = %+v, want themes %+v, default default, current dark", resp, want)
+	}
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/stroganoff/internal/config"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// changes. Checks happen during handshakes, so an idle server does no work.
+const certCheckInterval = time.Second
+
+// certReloader serves a certificate loaded from files and reloads it when
+// they change, so renewed certificates are picked up without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	stamp   string
+	checked time.Time
+}
+
+// newCertReloader loads the certificate and key from the given files
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+
+	stamp, err := r.fileStamp()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.cert = &cert
+	r.stamp = stamp
+	r.checked = time.Now()
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) >= certCheckInterval {
+		r.checked = time.Now()
+		r.reload()
+	}
+	return r.cert, nil
+}
+
+// reload loads the certificate again if the files changed. A certificate
+// that fails to load, e.g. because only one of the files was replaced so
+// far, is reported and the previous one is kept.
+func (r *certReloader) reload() {
+	stamp, err := r.fileStamp()
+	if err != nil || stamp == r.stamp {
+		return
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		slog.Error("Failed to reload TLS certificate, keeping the previous one", "cert", r.certFile, "error", err)
+		return
+	}
+
+	r.cert = &cert
+	r.stamp = stamp
+	slog.Info("Reloaded TLS certificate", "cert", r.certFile)
+}
+
+// fileStamp identifies the current version of the certificate files
+func (r *certReloader) fileStamp() (string, error) {
+	var stamp string
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp, nil
+}
+
+// newTLSConfig builds the TLS configuration from the server config
+func newTLSConfig(cfg config.ServerConfig) (*tls.Config, error) {
+	reloader, err := newCertReloader(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if cfg.TLSMinVersion != "" {
+		version, ok := config.TLSVersion(cfg.TLSMinVersion)
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q", cfg.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	for _, name := range cfg.TLSCipherSuites {
+		id, ok := config.CipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite %q", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+
+	return tlsConfig, nil
+}
+
+// redirectHandler redirects plain HTTP requests to the HTTPS listener on
+// httpsPort
+func redirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hostname drops the port and the brackets of IPv6 addresses
+		hostname := (&url.URL{Host: r.Host}).Hostname()
+		host := net.JoinHostPort(hostname, strconv.Itoa(httpsPort))
+		if httpsPort == 443 {
+			host = strings.TrimSuffix(host, ":443")
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
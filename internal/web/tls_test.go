@@ -0,0 +1,143 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/stroganoff/internal/config"
+)
+
+// writeCert writes a self-signed certificate for commonName and its key
+func writeCert(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloaderPicksUpRenewedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "first")
+
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader failed: %v", err)
+	}
+	if name := servedName(t, r); name != "first" {
+		t.Fatalf("served %q, want first", name)
+	}
+
+	// A broken key pair keeps the previous certificate
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r.checked = time.Time{}
+	if name := servedName(t, r); name != "first" {
+		t.Fatalf("served %q after a failed reload, want first", name)
+	}
+
+	writeCert(t, certFile, keyFile, "second")
+	r.checked = time.Time{}
+	if name := servedName(t, r); name != "second" {
+		t.Fatalf("served %q, want renewed certificate", name)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.ServerConfig{
+		TLSCert:         filepath.Join(dir, "tls.crt"),
+		TLSKey:          filepath.Join(dir, "tls.key"),
+		TLSMinVersion:   "1.3",
+		TLSCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	}
+	writeCert(t, cfg.TLSCert, cfg.TLSKey, "localhost")
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("newTLSConfig failed: %v", err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("MinVersion = %x, want TLS 1.3", tlsConfig.MinVersion)
+	}
+	if len(tlsConfig.CipherSuites) != 1 || tlsConfig.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatalf("CipherSuites = %v", tlsConfig.CipherSuites)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"example.com:8080", 443, "https://example.com/api/heartbeat?x=1"},
+		{"example.com:8080", 8443, "https://example.com:8443/api/heartbeat?x=1"},
+		{"example.com", 8443, "https://example.com:8443/api/heartbeat?x=1"},
+		{"[::1]:8080", 443, "https://[::1]/api/heartbeat?x=1"},
+		{"[::1]:8080", 8443, "https://[::1]:8443/api/heartbeat?x=1"},
+		{"[::1]", 443, "https://[::1]/api/heartbeat?x=1"},
+		{"[::1]", 8443, "https://[::1]:8443/api/heartbeat?x=1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com:8080/api/heartbeat?x=1", nil)
+		req.Host = test.host
+		rec := httptest.NewRecorder()
+		redirectHandler(test.port).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != test.want {
+			t.Fatalf("host %s, port %d: got %d %q, want redirect to %q", test.host, test.port, rec.Code, rec.Header().Get("Location"), test.want)
+		}
+	}
+}
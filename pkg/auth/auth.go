@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"fmt"
 	"strings"
 	"time"
@@ -9,6 +10,10 @@ import (
 	"github.com/yourusername/stroganoff/internal/config"
 )
 
+// AdminScope is the scope of tokens allowed to use the admin endpoints and
+// to create other admin tokens
+const AdminScope = "admin"
+
 // Token represents an authentication token
 type Token struct {
 	Value     string
@@ -39,6 +44,9 @@ func (a *Authenticator) ValidateToken(token string) bool {
 	if token == "" {
 		return false
 	}
+	if isAdminToken(token) {
+		return true
+	}
 
 	// Check if token exists and is not expired
 	if t, ok := a.tokens[token]; ok {
@@ -67,8 +75,12 @@ func (a *Authenticator) RevokeToken(token string) {
 	delete(a.tokens, token)
 }
 
-// HasScope checks if a token has a specific scope
+// HasScope checks if a token has a specific scope. The configured
+// api.admin_token has the admin scope.
 func (a *Authenticator) HasScope(token, scope string) bool {
+	if scope == AdminScope && isAdminToken(token) {
+		return true
+	}
 	if t, ok := a.tokens[token]; ok {
 		for _, s := range t.Scopes {
 			if s == scope {
@@ -79,6 +91,12 @@ func (a *Authenticator) HasScope(token, scope string) bool {
 	return false
 }
 
+// isAdminToken reports whether token is the configured api.admin_token
+func isAdminToken(token string) bool {
+	admin := config.GetInstance().GetAPI().AdminToken
+	return admin != "" && subtle.ConstantTimeCompare([]byte(token), []byte(admin)) == 1
+}
+
 // generateToken generates a random token
 func generateToken() string {
 	timestamp := time.Now().UnixNano()
@@ -87,6 +105,13 @@ func generateToken() string {
 	return fmt.Sprintf("%x", hash)
 }
 
+// TokenID returns a short identifier for token that is safe to log. It
+// identifies the token without revealing it.
+func TokenID(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%x", hash[:6])
+}
+
 // ExtractToken extracts token from authorization header
 func ExtractToken(authHeader string) string {
 	parts := strings.Split(authHeader, " ")
@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/yourusername/stroganoff/internal/config"
 )
 
 func TestCreateToken(t *testing.T) {
@@ -82,3 +85,34 @@ func TestExtractToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenID(t *testing.T) {
+	token := NewAuthenticator().CreateToken([]string{"read"}, time.Hour)
+
+	id := TokenID(token)
+	if id == "" || strings.Contains(token, id) {
+		t.Fatalf("TokenID(%q) = %q, want a short identifier not revealing the token", token, id)
+	}
+	if TokenID(token) != id {
+		t.Fatal("TokenID should be stable")
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	admin := strings.Repeat("a", 32)
+	if err := config.GetInstance().Load([]byte("api:\n  auth_enabled: true\n  admin_token: " + admin + "\n")); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer config.GetInstance().Load([]byte("api:\n  auth_enabled: false\n"))
+
+	a := NewAuthenticator()
+	if !a.ValidateToken(admin) || !a.HasScope(admin, AdminScope) {
+		t.Fatal("configured admin token should be valid with the admin scope")
+	}
+	if a.HasScope(admin, "read") {
+		t.Fatal("configured admin token should only have the admin scope")
+	}
+	if a.ValidateToken(admin[1:]) || a.HasScope(admin+"x", AdminScope) {
+		t.Fatal("tokens differing from the admin token should not be accepted")
+	}
+}
@@ -9,10 +9,10 @@ import (
 
 // Limiter implements token bucket algorithm for rate limiting
 type Limiter struct {
-	mu       sync.RWMutex
-	buckets  map[string]*bucket
-	ticker   *time.Ticker
-	stopCh   chan struct{}
+	mu      sync.RWMutex
+	buckets map[string]*bucket
+	ticker  *time.Ticker
+	stopCh  chan struct{}
 }
 
 type bucket struct {
@@ -55,7 +55,7 @@ func (l *Limiter) Allow(identifier string) bool {
 	}
 
 	// Refill bucket based on elapsed time
-	window := time.Duration(cfg.RateLimitWindow) * time.Second
+	window := cfg.RateLimitWindow.Duration()
 	elapsed := now.Sub(b.lastReset)
 	refillRate := float64(cfg.RateLimit) / window.Seconds()
 	b.tokens += refillRate * elapsed.Seconds()
@@ -0,0 +1,12 @@
+// Package web holds the web interface assets compiled into the binary
+package web
+
+//go:generate go run ../cmd/compress-assets themes
+
+import "embed"
+
+// Themes holds the built-in themes, one directory per theme under
+// "themes", e.g. themes/default/pages/index.html
+//
+//go:embed themes
+var Themes embed.FS
@@ -1,11 +1,14 @@
-// GOCR Web Interface - Application JavaScript
+// Stroganoff Web Interface - Application JavaScript
 
 document.addEventListener('DOMContentLoaded', function() {
-    console.log('GOCR application initialized');
+    console.log('Stroganoff application initialized');
 
     // Add event listeners for navigation
     setupNavigation();
 
+    // Apply a theme as soon as it is picked
+    setupThemeSwitcher();
+
     // Initialize API calls
     initializeAPI();
 });
@@ -29,6 +32,16 @@ function setupNavigation() {
     });
 }
 
+function setupThemeSwitcher() {
+    const select = document.querySelector('.theme-switcher select');
+
+    if (select) {
+        select.addEventListener('change', function() {
+            this.form.submit();
+        });
+    }
+}
+
 function initializeAPI() {
     // Check health status
     checkHealth();